@@ -9,12 +9,106 @@ func TestAllSpecs(t *testing.T) {
   r := gospec.NewRunner()
   r.AddSpec(Float64ContextSpec)
   r.AddSpec(Float64AndBooleanContextSpec)
+  r.AddSpec(NumericContextSpec)
+  r.AddSpec(NumericHintSpec)
+  r.AddSpec(NandNorSpec)
+  r.AddSpec(ImplicationSpec)
   r.AddSpec(IntContextSpec)
   r.AddSpec(MultiValueReturnSpec)
   r.AddSpec(ErrorSpec)
+  r.AddSpec(EmptyContextSpec)
   r.AddSpec(NumRemainingValuesSpec)
+  r.AddSpec(EvalNSpec)
+  r.AddSpec(EvalTokensSpec)
+  r.AddSpec(EvalBytesSpec)
+  r.AddSpec(EvalAllSpec)
+  r.AddSpec(EvalTimeoutSpec)
+  r.AddSpec(TokenFuncSpec)
+  r.AddSpec(EvalConcurrentSpec)
+  r.AddSpec(HistorySpec)
+  r.AddSpec(ContextFuncSpec)
+  r.AddSpec(NamesSpec)
+  r.AddSpec(IsBuiltinSpec)
+  r.AddSpec(RemoveSpec)
+  r.AddSpec(DisableSpec)
+  r.AddSpec(TupleSpec)
+  r.AddSpec(ApplySpec)
+  r.AddSpec(AddFuncNamesSpec)
+  r.AddSpec(CapabilitiesSpec)
   r.AddSpec(ParsingSpec)
   r.AddSpec(ParseOrderSpec)
+  r.AddSpec(StrictIntegersSpec)
+  r.AddSpec(TypeStringSpec)
   r.AddSpec(IntOperatorSpec)
+  r.AddSpec(FloorDivSpec)
+  r.AddSpec(IntPowSpec)
+  r.AddSpec(Int64ContextSpec)
+  r.AddSpec(Int32ContextSpec)
+  r.AddSpec(BigFloatContextSpec)
+  r.AddSpec(DecimalSpec)
+  r.AddSpec(DecimalContextSpec)
+  r.AddSpec(FloatEpsilonSpec)
+  r.AddSpec(NaNSpec)
+  r.AddSpec(SignSpec)
+  r.AddSpec(ClampPctSpec)
+  r.AddSpec(RoundToSpec)
+  r.AddSpec(NegativeLiteralSpec)
+  r.AddSpec(HeterogeneousTypesSpec)
+  r.AddSpec(InterfaceParamSpec)
+  r.AddSpec(CharLiteralSpec)
+  r.AddSpec(DelimiterSpec)
+  r.AddSpec(TokenizerSpec)
+  r.AddSpec(GroupingSpec)
+  r.AddSpec(UnicodeNameSpec)
+  r.AddSpec(ResultSpec)
+  r.AddSpec(GenericEvalSpec)
+  r.AddSpec(DefaultNumericSpec)
+  r.AddSpec(OperatorInfoSpec)
+  r.AddSpec(TracerSpec)
+  r.AddSpec(ValueAccessHookSpec)
+  r.AddSpec(StatsSpec)
+  r.AddSpec(PureOnlySpec)
+  r.AddSpec(DefaultValueSpec)
+  r.AddSpec(ResolverSpec)
+  r.AddSpec(UnknownTermSpec)
+  r.AddSpec(ListLiteralSpec)
+  r.AddSpec(VectorContextSpec)
+  r.AddSpec(MatrixContextSpec)
+  r.AddSpec(StatsContextSpec)
+  r.AddSpec(StringContextSpec)
+  r.AddSpec(TrigContextSpec)
+  r.AddSpec(HyperbolicSpec)
+  r.AddSpec(MathContextSpec)
+  r.AddSpec(FuncDocSpec)
+  r.AddSpec(FuncSignatureSpec)
+  r.AddSpec(AddFuncErrorSpec)
+  r.AddSpec(StrictValuesSpec)
+  r.AddSpec(LazyValueSpec)
+  r.AddSpec(OverrideSpec)
+  r.AddSpec(EvalWithSpec)
+  r.AddSpec(EvalBatchSpec)
+  r.AddSpec(ClassifySpec)
+  r.AddSpec(LiteralTypeSpec)
+  r.AddSpec(ParseSpec)
+  r.AddSpec(EvalNodeSpec)
+  r.AddSpec(CheckSpec)
+  r.AddSpec(ValidateSpec)
+  r.AddSpec(IsCompleteSpec)
+  r.AddSpec(TypeCheckSpec)
+  r.AddSpec(CommentSpec)
+  r.AddSpec(EqualSpec)
+  r.AddSpec(WalkSpec)
+  r.AddSpec(SubstituteSpec)
+  r.AddSpec(ConstantFoldingSpec)
+  r.AddSpec(EngineSpec)
+  r.AddSpec(BindSpec)
+  r.AddSpec(FoldSpec)
+  r.AddSpec(MapSpec)
+  r.AddSpec(NthSpec)
+  r.AddSpec(UnexpectedEndSpec)
+  r.AddSpec(EvalToStringSpec)
+  r.AddSpec(FormatterSpec)
+  r.AddSpec(REPLSpec)
+  r.AddSpec(EvalScriptSpec)
   gospec.MainGoTest(r, t)
 }
@@ -0,0 +1,34 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+)
+
+// Returns the func produced by the first subexpression of apply, see Context.
+func appliedFunc(vs []reflect.Value) (function, error) {
+  if len(vs) != 1 {
+    return function{}, &Error{fmt.Sprintf("'apply' needs a single func, but its first expression produced %d value(s).", len(vs)), nil, nil}
+  }
+  if !vs[0].IsValid() || vs[0].Kind() != reflect.Func {
+    return function{}, &Error{fmt.Sprintf("'apply' needs a func, not a %v.", typeOf(vs[0])), nil, nil}
+  }
+  if vs[0].IsNil() {
+    return function{}, &Error{"'apply' was given a nil func.", nil, nil}
+  }
+  if err := checkApplied(vs[0].Type()); err != nil {
+    return function{}, err
+  }
+  return function{f: vs[0], num: vs[0].Type().NumIn()}, nil
+}
+
+// Returns an error if typ is not the type of a func that apply can call.
+func checkApplied(typ reflect.Type) error {
+  if typ == nil || typ.Kind() != reflect.Func {
+    return &Error{fmt.Sprintf("'apply' needs a func, not a %v.", typ), nil, nil}
+  }
+  if typ.IsVariadic() {
+    return &Error{fmt.Sprintf("'apply' cannot call the variadic %v.", typ), nil, nil}
+  }
+  return nil
+}
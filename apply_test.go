@@ -0,0 +1,73 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func ApplySpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("adder", func(a int) func(int) int { return func(b int) int { return a + b } })
+    context.AddFunc("curried", func(a int) func(int) func(int) int {
+      return func(b int) func(int) int { return func(c int) int { return a*100 + b*10 + c } }
+    })
+    context.AddFunc("split", func(a int) func() (int, int) { return func() (int, int) { return a / 10, a % 10 } })
+    context.AddFunc("twice", func(f func(int) int, a int) int { return f(f(a)) })
+    context.SetValue("inc", func(a int) int { return a + 1 })
+    context.SetValue("sum", func(a ...int) int { return len(a) })
+    context.SetEngine(engine)
+    expectInts := func(expression string, expected ...int) {
+      res, err := context.Eval(expression)
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, len(expected))
+      for i := range res {
+        c.Expect(int(res[i].Int()), Equals, expected[i])
+      }
+    }
+    c.Specify("apply calls a func produced by an expression.", func() {
+      expectInts("apply adder 5 3", 8)
+      expectInts("* 2 apply adder + 1 2 10", 26)
+      expectInts("apply apply curried 1 2 3", 123)
+      expectInts("apply split 47", 4, 7)
+      expectInts("apply inc 9", 10)
+    })
+    c.Specify("Func values are only called by apply.", func() {
+      expectInts("twice adder 5 1", 11)
+      expectInts("twice inc 1", 3)
+    })
+    c.Specify("apply needs a func with a fixed number of parameters.", func() {
+      _, err := context.Eval("apply 3 4")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("apply sum 1 2")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("apply adder 5")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("apply")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("apply adder 5 1.5")
+      c.Expect(err, Not(Equals), nil)
+    })
+  }
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("adder", func(a int) func(int) int { return func(b int) int { return a + b } })
+  c.Specify("Parse, Check and TypeCheck use the type of the func.", func() {
+    root, err := context.Parse("+ apply adder 5 3 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(root.Children[0].Term, Equals, "apply")
+    c.Expect(len(root.Children[0].Children), Equals, 2)
+    c.Expect(context.Check("apply adder 5 3"), Equals, nil)
+    c.Expect(context.Check("apply adder 5"), Not(Equals), nil)
+    c.Expect(context.Check("apply + 1 2 3"), Not(Equals), nil)
+    t, err := context.TypeCheck("apply adder 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(t, Equals, reflect.TypeOf(0))
+    t, err = context.TypeCheck("adder 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(t, Equals, reflect.TypeOf(func(int) int { return 0 }))
+  })
+}
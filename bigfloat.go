@@ -0,0 +1,42 @@
+package polish
+
+import (
+  "math/big"
+)
+
+// Adds several operators to the Context, all of which use *big.Float for any
+// numerical values, and makes numeric literals parse as *big.Float with prec
+// bits of mantissa by putting BigFloat at the front of the parse order.
+// Literals are parsed from their decimal text, so 0.1 is rounded to the
+// nearest value at prec bits rather than to the nearest float64.
+// Every result is rounded to prec bits, using big.ToNearestEven, no matter
+// what precision its operands have, so precision never grows or shrinks as
+// values pass through operations.  Values set with SetValue keep whatever
+// precision they were created with until they are used as an operand.
+// Operations whose result would be NaN, such as / 0 0, are errors.
+//   Functions: + - * / abs neg sqrt < <= > >= ==
+func AddBigFloatMathContext(c *Context, prec uint) {
+  c.big_prec = prec
+  order := []Type{BigFloat}
+  for _, t := range c.parse_order {
+    if t != BigFloat {
+      order = append(order, t)
+    }
+  }
+  c.parse_order = order
+  result := func() *big.Float {
+    return new(big.Float).SetPrec(prec)
+  }
+  c.addBuiltin("+", func(a, b *big.Float) *big.Float { return result().Add(a, b) }, "Sum of two *big.Floats.")
+  c.addBuiltin("-", func(a, b *big.Float) *big.Float { return result().Sub(a, b) }, "Difference of two *big.Floats, a - b.")
+  c.addBuiltin("*", func(a, b *big.Float) *big.Float { return result().Mul(a, b) }, "Product of two *big.Floats.")
+  c.addBuiltin("/", func(a, b *big.Float) *big.Float { return result().Quo(a, b) }, "Quotient of two *big.Floats, a / b.")
+  c.addBuiltin("abs", func(a *big.Float) *big.Float { return result().Abs(a) }, "Absolute value.")
+  c.addBuiltin("neg", func(a *big.Float) *big.Float { return result().Neg(a) }, "Negation.")
+  c.addBuiltin("sqrt", func(a *big.Float) *big.Float { return result().Sqrt(a) }, "Square root, a must not be negative.")
+  c.addBuiltin("<", func(a, b *big.Float) bool { return a.Cmp(b) < 0 }, "True if a < b.")
+  c.addBuiltin("<=", func(a, b *big.Float) bool { return a.Cmp(b) <= 0 }, "True if a <= b.")
+  c.addBuiltin(">", func(a, b *big.Float) bool { return a.Cmp(b) > 0 }, "True if a > b.")
+  c.addBuiltin(">=", func(a, b *big.Float) bool { return a.Cmp(b) >= 0 }, "True if a >= b.")
+  c.addBuiltin("==", func(a, b *big.Float) bool { return a.Cmp(b) == 0 }, "True if a and b are exactly equal.")
+}
@@ -0,0 +1,47 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "math/big"
+)
+
+func BigFloatContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddBigFloatMathContext(context, 200)
+  evalBig := func(expression string) *big.Float {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    return res[0].Interface().(*big.Float)
+  }
+  c.Specify("Literals are parsed at the given precision.", func() {
+    x := evalBig("0.1")
+    c.Expect(x.Prec(), Equals, uint(200))
+    c.Expect(evalBig("2").Prec(), Equals, uint(200))
+  })
+  c.Specify("Results avoid float64 rounding.", func() {
+    // 0.1 + 0.2 is not 0.3 with float64s.
+    res, err := context.Eval("== + 0.1 0.2 0.3")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+    c.Expect(evalBig("+ 1e30 1").Text('f', 0), Equals, "1000000000000000000000000000001")
+  })
+  c.Specify("Results have the context's precision.", func() {
+    context.SetValue("low", new(big.Float).SetPrec(10).SetFloat64(1))
+    c.Expect(evalBig("+ low low").Prec(), Equals, uint(200))
+    c.Expect(evalBig("sqrt 2").Prec(), Equals, uint(200))
+  })
+  c.Specify("Comparisons work.", func() {
+    res, err := context.Eval("< 1 1.0000000000000000000000001")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+  })
+  c.Specify("NaN results are errors.", func() {
+    _, err := context.Eval("/ 0 0")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("sqrt -1")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
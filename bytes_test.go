@@ -0,0 +1,68 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "fmt"
+  "testing"
+)
+
+func EvalBytesSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("concat", func(a, b string) string { return a + b })
+  context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+  context.SetValue("x", 7)
+  context.SetComments(true)
+  c.Specify("EvalBytes matches Eval.", func() {
+    for _, expression := range []string{
+      "+ 1 2",
+      "  * x\t+ 2\n3  ",
+      "[1 2 makeTwo]",
+      "concat 'a b' \"c\"",
+      "concat héllo wörld",
+      "+ 1 # a comment\n 2",
+      "+ 1",
+      "]",
+      "/ 1 0",
+      "",
+      "+ 1 \xff",
+    } {
+      res1, err1 := context.Eval(expression)
+      res2, err2 := context.EvalBytes([]byte(expression))
+      c.Expect(fmt.Sprint(err2), Equals, fmt.Sprint(err1))
+      c.Expect(len(res2), Equals, len(res1))
+      for i := range res1 {
+        if i < len(res2) {
+          c.Expect(fmt.Sprint(res2[i].Interface()), Equals, fmt.Sprint(res1[i].Interface()))
+        }
+      }
+    }
+  })
+  c.Specify("The buffer can be reused after EvalBytes returns.", func() {
+    buf := []byte("concat abc def")
+    res, err := context.EvalBytes(buf)
+    c.Assume(err, Equals, nil)
+    copy(buf, "xxxxxxxxxxxxxx")
+    c.Expect(res[0].String(), Equals, "abcdef")
+  })
+  c.Specify("Grouping and custom tokenizers are used as they are by Eval.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetGrouping(true)
+    res, err := context.EvalBytes([]byte("* (+ 1 2) 4"))
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 12)
+    context.SetTokenizer(func(s string) ([]string, error) { return []string{"+", "2", "3"}, nil })
+    res, err = context.EvalBytes([]byte("anything"))
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 5)
+  })
+  c.Specify("Short terms are not copied.", func() {
+    buf := []byte("+ 1 * 2 3                                                       ")
+    bytes := testing.AllocsPerRun(100, func() { context.EvalBytes(buf) })
+    strs := testing.AllocsPerRun(100, func() { context.Eval(string(buf)) })
+    c.Expect(bytes < strs, Equals, true)
+  })
+}
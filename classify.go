@@ -0,0 +1,73 @@
+package polish
+
+import (
+  "reflect"
+)
+
+// A Kind describes how a term would be interpreted during evaluation, see
+// Classify.
+type Kind int
+const(
+  KindUnknown Kind = iota
+  KindFunc
+  KindValue
+  KindIntLiteral
+  KindFloatLiteral
+  KindStringLiteral
+  KindCharLiteral
+  KindSpecialForm
+)
+
+// Reports how a term would be interpreted if it appeared in an expression,
+// without evaluating anything.  Special forms such as bind and map are
+// KindSpecialForm, then functions and values are checked, and then literals
+// are tried in the parse order, so a term that looks like an integer is a
+// KindFloatLiteral if Float comes before Integer.  Integer literals are
+// KindIntLiteral even if SetDefaultNumeric makes them float64s.  Terms that
+// could not be evaluated, including names disabled with Disable, and brackets,
+// are KindUnknown.
+func (c *Context) Classify(term string) Kind {
+  if term == "[" || term == "]" {
+    return KindUnknown
+  }
+  if special_forms[term] {
+    return KindSpecialForm
+  }
+  if _, ok := c.lookupFunc(term); ok {
+    return KindFunc
+  }
+  if c.hasValue(term) {
+    return KindValue
+  }
+  if c.disabled[term] {
+    return KindUnknown
+  }
+  val, typ, err := c.parseLiteral(term)
+  if err != nil || val == (reflect.Value{}) {
+    return KindUnknown
+  }
+  switch typ {
+  case Integer, Int64, Int32:
+    return KindIntLiteral
+  case Float, BigFloat, FixedPoint:
+    return KindFloatLiteral
+  case String:
+    return KindStringLiteral
+  case Char:
+    return KindCharLiteral
+  }
+  return KindUnknown
+}
+
+// Reports which Type in the parse order would parse term as a literal, ok is
+// false if none of them would.  Functions and values are not consulted, so
+// this describes how term would be parsed if nothing else claimed it.  The
+// default numeric type is not taken into account, so 1 is an Integer even if
+// SetDefaultNumeric would make it a float64.
+func (c *Context) LiteralType(term string) (t Type, ok bool) {
+  val, typ, err := c.parseLiteral(term)
+  if err != nil || val == (reflect.Value{}) {
+    return 0, false
+  }
+  return typ, true
+}
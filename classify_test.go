@@ -0,0 +1,76 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+)
+
+func ClassifySpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  computed := false
+  context.SetLazyValue("table", func() interface{} { computed = true; return 1 })
+  c.Specify("Terms are classified like evaluation would treat them.", func() {
+    c.Expect(context.Classify("+"), Equals, polish.KindFunc)
+    c.Expect(context.Classify("pi"), Equals, polish.KindValue)
+    c.Expect(context.Classify("table"), Equals, polish.KindValue)
+    c.Expect(computed, Equals, false)
+    c.Expect(context.Classify("-3"), Equals, polish.KindIntLiteral)
+    c.Expect(context.Classify("1e3"), Equals, polish.KindFloatLiteral)
+    c.Expect(context.Classify("'x'"), Equals, polish.KindCharLiteral)
+    c.Expect(context.Classify("hello"), Equals, polish.KindStringLiteral)
+    c.Expect(context.Classify("["), Equals, polish.KindUnknown)
+  })
+  c.Specify("Classification follows the parse order.", func() {
+    context.SetParseOrder(polish.Float)
+    c.Expect(context.Classify("3"), Equals, polish.KindFloatLiteral)
+    c.Expect(context.Classify("hello"), Equals, polish.KindUnknown)
+    context.SetParseOrder(polish.Integer, polish.Float, polish.Char, polish.String)
+  })
+  c.Specify("Special forms are not literals.", func() {
+    for _, term := range []string{"apply", "bind", "fold", "map", "nth", "tuple", "untuple"} {
+      c.Expect(context.Classify(term), Equals, polish.KindSpecialForm)
+    }
+  })
+  c.Specify("Disabled names cannot be evaluated.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.Disable("^", "hidden")
+    c.Expect(context.Classify("^"), Equals, polish.KindUnknown)
+    c.Expect(context.Classify("hidden"), Equals, polish.KindUnknown)
+    context.SetValue("hidden", 1.0)
+    c.Expect(context.Classify("hidden"), Equals, polish.KindValue)
+  })
+  c.Specify("Overrides are values.", func() {
+    restore := context.WithOverride("+", 1)
+    c.Expect(context.Classify("+"), Equals, polish.KindValue)
+    restore()
+  })
+}
+
+func LiteralTypeSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  expectType := func(term string, expected polish.Type) {
+    t, ok := context.LiteralType(term)
+    c.Expect(ok, Equals, true)
+    c.Expect(t, Equals, expected)
+  }
+  c.Specify("Literals are reported in the default parse order.", func() {
+    expectType("12", polish.Integer)
+    expectType("1e3", polish.Float)
+    expectType("'x'", polish.Char)
+    expectType("hello", polish.String)
+  })
+  c.Specify("Functions and values are not consulted.", func() {
+    expectType("+", polish.String)
+    expectType("pi", polish.String)
+  })
+  c.Specify("The parse order is respected.", func() {
+    context.SetParseOrder(polish.Float, polish.Integer)
+    expectType("12", polish.Float)
+    _, ok := context.LiteralType("hello")
+    c.Expect(ok, Equals, false)
+  })
+}
@@ -0,0 +1,213 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+  "strconv"
+)
+
+// An Expr is an expression that has been prepared by Compile so that it can be
+// evaluated many times, with different values for its free variables each
+// time.
+type Expr struct {
+  c          *Context
+  expression string
+  terms      []string
+
+  // Names of the free variables, which must be bound on every evaluation.
+  vars []string
+
+  // Values of subexpressions that were evaluated by constant folding, keyed by
+  // the terms that replaced them.
+  consts map[string][]reflect.Value
+
+  // If the whole expression was folded, this is its result.
+  constant bool
+  result   []reflect.Value
+}
+
+// Prepares an expression for repeated evaluation.  vars lists the free
+// variables of the expression, whose values are supplied each time the Expr is
+// evaluated rather than being set on the Context.  A free variable cannot have
+// the same name as a function.
+func (c *Context) Compile(expression string, vars ...string) (*Expr, error) {
+  for _, name := range vars {
+    if _, ok := c.funcs[name]; ok {
+      return nil, &Error{fmt.Sprintf("Cannot use the function '%s' as a free variable.", name), nil, nil}
+    }
+    if special_forms[name] {
+      return nil, &Error{fmt.Sprintf("Cannot use the reserved name '%s' as a free variable.", name), nil, nil}
+    }
+  }
+  terms, err := c.tokenize(expression)
+  if err != nil {
+    return nil, err
+  }
+  e := &Expr{
+    c:          c,
+    expression: expression,
+    terms:      terms,
+    vars:       vars,
+  }
+  if c.fold {
+    p := makeParser(c, e.terms)
+    root, _, err := p.parse("", 0)
+    if err != nil {
+      return nil, err
+    }
+    free := make(map[string]bool)
+    for _, name := range vars {
+      free[name] = true
+    }
+    e.consts = make(map[string][]reflect.Value)
+    if e.fold(root, free) {
+      e.result, e.constant = e.evalConstant(root)
+    }
+    e.terms = root.appendTerms(nil)
+  }
+  return e, nil
+}
+
+// Evaluates a subexpression that has no free variables, ok is false if it
+// could not be evaluated, in which case the error will come up again when the
+// Expr is evaluated.
+func (e *Expr) evalConstant(n *Node) (vs []reflect.Value, ok bool) {
+  vs, err := e.c.evaluate(e.expression, &evaluation{c: e.c, terms: n.appendTerms(nil), consts: e.consts})
+  return vs, err == nil
+}
+
+// Returns whether n is constant, meaning that it has no free variables and only
+// calls pure functions.  Constant children of a node that is not itself
+// constant are evaluated and replaced with a single term that produces the
+// same values.
+func (e *Expr) fold(n *Node, free map[string]bool) bool {
+  constant := !free[n.Term]
+  first := 0
+  if n.Term == "bind" {
+    // Bound names are not known until the expression is evaluated, and the
+    // names themselves must be left alone.
+    for _, name := range n.Children[0].Children {
+      free[name.Term] = true
+    }
+    constant = false
+    first = 1
+  } else if f, ok := e.c.lookupFunc(n.Term); ok {
+    if f.token {
+      // The terms a token function consumes are raw syntax rather than
+      // subexpressions, so they must be left exactly as they are.
+      return false
+    }
+    constant = f.pure
+  } else if n.Term == "apply" || e.holdsFunc(n.Term) {
+    // A func value may be impure, and apply calls it, so neither can be
+    // evaluated ahead of time.
+    constant = false
+  }
+  foldable := make([]bool, len(n.Children))
+  for i, child := range n.Children[first:] {
+    foldable[first+i] = e.fold(child, free)
+    constant = constant && foldable[first+i]
+  }
+  if constant {
+    return true
+  }
+  var children []*Node
+  for i, child := range n.Children {
+    _, call := e.c.lookupFunc(child.Term)
+    if !foldable[i] || (!call && len(child.Children) == 0) {
+      children = append(children, child)
+      continue
+    }
+    vs, ok := e.evalConstant(child)
+    if !ok {
+      children = append(children, child)
+      continue
+    }
+    // Terms never contain a NUL, so this cannot collide with a real name.
+    name := "\x00" + strconv.Itoa(len(e.consts))
+    e.consts[name] = vs
+    children = append(children, &Node{Term: name})
+  }
+  n.Children = children
+  return false
+}
+
+// Returns whether name is a value in the Context that holds a func, or may
+// hold one because it is a lazy value.
+func (e *Expr) holdsFunc(name string) bool {
+  v, ok := e.c.overrides[name]
+  if !ok {
+    v, ok = e.c.vals[name]
+  }
+  if !ok {
+    if _, lazy := e.c.lazy[name]; lazy {
+      // Finding out would compute the value, which should wait until an
+      // evaluation needs it.
+      return true
+    }
+    v, ok = e.c.historyValue(name)
+  }
+  return ok && v.IsValid() && v.Kind() == reflect.Func
+}
+
+// A RowError is the error from a single row given to EvalBatch.
+type RowError struct {
+  // Index of the row that failed.
+  Index int
+
+  Err error
+}
+
+func (e *RowError) Error() string {
+  return fmt.Sprintf("Row %d: %v", e.Index, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+  return e.Err
+}
+
+// Evaluates the expression once for each row, with the row's values bound to
+// the free variables as with EvalWith, and returns the results in the same
+// order as the rows.  Evaluation stops at the first row that fails, and the
+// error is a *RowError giving the index of that row.
+func (e *Expr) EvalBatch(rows []map[string]interface{}) ([][]reflect.Value, error) {
+  results := make([][]reflect.Value, len(rows))
+  for i, row := range rows {
+    vars := make(map[string]reflect.Value, len(row))
+    for name, v := range row {
+      vars[name] = reflect.ValueOf(v)
+    }
+    vs, err := e.EvalWith(vars)
+    if err != nil {
+      return nil, &RowError{i, err}
+    }
+    results[i] = vs
+  }
+  return results, nil
+}
+
+// Evaluates the expression with the given values for its free variables.  The
+// bindings are only visible to this evaluation and take precedence over values
+// in the Context, which is left unchanged.  Every free variable must be bound.
+func (e *Expr) EvalWith(vars map[string]reflect.Value) ([]reflect.Value, error) {
+  for _, name := range e.vars {
+    if _, ok := vars[name]; !ok {
+      return nil, &Error{fmt.Sprintf("Free variable '%s' of (%s) is not bound.", name, e.expression), nil, nil}
+    }
+  }
+  if e.constant {
+    return append([]reflect.Value(nil), e.result...), nil
+  }
+  return e.c.evaluate(e.expression, &evaluation{c: e.c, terms: e.terms, bindings: vars, consts: e.consts})
+}
+
+// When set, Compile evaluates subexpressions that have no free variables and
+// only call functions added with AddPureFunc ahead of time, so that they are
+// not evaluated again every time the Expr is.  Values in the Context are
+// treated as constants, so an Expr compiled this way will not see later
+// changes made with SetValue unless they are free variables.  Values that
+// hold funcs, lazy values, which may hold funcs, and apply are never folded,
+// since the funcs may not be pure.
+func (c *Context) SetConstantFolding(fold bool) {
+  c.fold = fold
+}
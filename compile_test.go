@@ -0,0 +1,175 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func EvalWithSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  c.Specify("Compiled expressions can be evaluated with different bindings.", func() {
+    expr, err := context.Compile("+ * x x y", "x", "y")
+    c.Assume(err, Equals, nil)
+    for _, x := range []float64{0, 1, 2.5} {
+      res, err := expr.EvalWith(map[string]reflect.Value{
+        "x": reflect.ValueOf(x),
+        "y": reflect.ValueOf(1.0),
+      })
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(res[0].Float(), Equals, x*x+1)
+    }
+  })
+  c.Specify("Bindings shadow values without changing the Context.", func() {
+    expr, err := context.Compile("* 2.0 pi", "pi")
+    c.Assume(err, Equals, nil)
+    res, err := expr.EvalWith(map[string]reflect.Value{"pi": reflect.ValueOf(3.0)})
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 6.0)
+    res, err = context.Eval("pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Not(Equals), 3.0)
+  })
+  c.Specify("Unbound free variables are errors.", func() {
+    expr, err := context.Compile("+ x y", "x", "y")
+    c.Assume(err, Equals, nil)
+    _, err = expr.EvalWith(map[string]reflect.Value{"x": reflect.ValueOf(1.0)})
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Functions cannot be free variables.", func() {
+    _, err := context.Compile("+ 1.0 2.0", "+")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func ConstantFoldingSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  calls := 0
+  context.AddPureFunc("slow", func(a float64) float64 { calls++; return a })
+  context.AddFunc("impure", func(a float64) float64 { calls++; return a })
+  context.AddPureFunc("two", func() (float64, float64) { calls++; return 1, 2 })
+  context.SetConstantFolding(true)
+  x := func(v float64) map[string]reflect.Value {
+    return map[string]reflect.Value{"x": reflect.ValueOf(v)}
+  }
+  c.Specify("Constant subexpressions are evaluated once.", func() {
+    calls = 0
+    expr, err := context.Compile("+ x slow * 2.0 pi", "x")
+    c.Assume(err, Equals, nil)
+    c.Expect(calls, Equals, 1)
+    for _, v := range []float64{1, 2, 3} {
+      res, err := expr.EvalWith(x(v))
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Float(), IsWithin(1e-9), v+2*3.141592653589793)
+    }
+    c.Expect(calls, Equals, 1)
+  })
+  c.Specify("Impure functions and free variables are not folded.", func() {
+    calls = 0
+    expr, err := context.Compile("+ impure 1.0 slow x", "x")
+    c.Assume(err, Equals, nil)
+    expr.EvalWith(x(1))
+    expr.EvalWith(x(2))
+    c.Expect(calls, Equals, 4)
+  })
+  c.Specify("Func values and apply are not folded.", func() {
+    tick := func(a float64) float64 { calls++; return a }
+    context.SetValue("tick", tick)
+    context.SetLazyValue("later", func() interface{} { return tick })
+    context.SetHistorySize(1)
+    defer context.SetHistorySize(0)
+    context.AddPureFunc("call", func(f func(float64) float64, a float64) float64 { return f(a) })
+    _, err := context.Eval("tick")
+    c.Assume(err, Equals, nil)
+    for _, expression := range []string{"+ x apply tick 1.0", "apply tick 1.0", "apply ans 1.0", "call tick 1.0", "call later 1.0", "call ans 1.0"} {
+      calls = 0
+      expr, err := context.Compile(expression, "x")
+      c.Assume(err, Equals, nil)
+      c.Expect(calls, Equals, 0)
+      expr.EvalWith(x(1))
+      expr.EvalWith(x(2))
+      c.Expect(calls, Equals, 2)
+    }
+  })
+  c.Specify("Folded multiple values are threaded as before.", func() {
+    calls = 0
+    expr, err := context.Compile("+ x - two", "x")
+    c.Assume(err, Equals, nil)
+    res, err := expr.EvalWith(x(5))
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Float(), Equals, 4.0)
+    res, err = expr.EvalWith(x(6))
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 5.0)
+    c.Expect(calls, Equals, 1)
+  })
+  c.Specify("Calls with no arguments are folded.", func() {
+    calls = 0
+    expr, err := context.Compile("- x two", "x")
+    c.Assume(err, Equals, nil)
+    res, err := expr.EvalWith(x(5))
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 2)
+    c.Expect(res[0].Float(), Equals, 4.0)
+    c.Expect(res[1].Float(), Equals, 2.0)
+    expr.EvalWith(x(6))
+    c.Expect(calls, Equals, 1)
+  })
+  c.Specify("Entirely constant expressions are evaluated once.", func() {
+    calls = 0
+    expr, err := context.Compile("two")
+    c.Assume(err, Equals, nil)
+    for i := 0; i < 3; i++ {
+      res, err := expr.EvalWith(nil)
+      c.Assume(err, Equals, nil)
+      c.Expect(len(res), Equals, 2)
+    }
+    c.Expect(calls, Equals, 1)
+  })
+  c.Specify("Malformed expressions fail to compile.", func() {
+    _, err := context.Compile("+ x", "x")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func EvalBatchSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  expr, err := context.Compile("* x + y 1.0", "x", "y")
+  c.Assume(err, Equals, nil)
+  c.Specify("Each row is evaluated in order.", func() {
+    res, err := expr.EvalBatch([]map[string]interface{}{
+      {"x": 2.0, "y": 1.0},
+      {"x": 3.0, "y": 0.0},
+      {"x": 0.5, "y": 3.0},
+    })
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 3)
+    c.Expect(res[0][0].Float(), Equals, 4.0)
+    c.Expect(res[1][0].Float(), Equals, 3.0)
+    c.Expect(res[2][0].Float(), Equals, 2.0)
+  })
+  c.Specify("Errors give the index of the row that failed.", func() {
+    _, err := expr.EvalBatch([]map[string]interface{}{
+      {"x": 2.0, "y": 1.0},
+      {"x": 3.0},
+    })
+    c.Assume(err, Not(Equals), nil)
+    row_err, ok := err.(*polish.RowError)
+    c.Assume(ok, Equals, true)
+    c.Expect(row_err.Index, Equals, 1)
+    _, err = expr.EvalBatch([]map[string]interface{}{{"x": 2, "y": 1.0}})
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.(*polish.RowError).Index, Equals, 0)
+  })
+  c.Specify("An empty batch has no results.", func() {
+    res, err := expr.EvalBatch(nil)
+    c.Expect(err, Equals, nil)
+    c.Expect(len(res), Equals, 0)
+  })
+}
@@ -0,0 +1,240 @@
+package polish
+
+import (
+  "fmt"
+  "math/big"
+  "strings"
+)
+
+// A Decimal is a fixed-point decimal number, an integer number of units of
+// 10^-Scale, so 12.34 is 1234 units at a scale of 2.  Decimals are exact, so
+// unlike float64s they are suitable for money.  The zero Decimal is 0.
+type Decimal struct {
+  units *big.Int
+  scale int
+}
+
+// How a Decimal is rounded when it has to lose digits.
+type RoundingMode int
+const(
+  // Rounds to the nearest value, and ties to the even neighbor.
+  RoundHalfEven RoundingMode = iota
+
+  // Rounds to the nearest value, and ties away from zero.
+  RoundHalfUp
+
+  // Rounds toward zero.
+  RoundDown
+
+  // Rounds away from zero.
+  RoundUp
+
+  // Rounds toward negative infinity.
+  RoundFloor
+
+  // Rounds toward positive infinity.
+  RoundCeiling
+)
+
+// Makes the Decimal units * 10^-scale, scale must not be negative.
+func NewDecimal(units int64, scale int) Decimal {
+  if scale < 0 {
+    panic(fmt.Sprintf("Decimal scale must not be negative, got %d.", scale))
+  }
+  return Decimal{big.NewInt(units), scale}
+}
+
+// Parses a decimal literal such as 12, -0.5, or 12.340, which must have at
+// least one digit before the point if it has one and at least one after.  The
+// scale is the number of digits after the point, so 12.340 has a scale of 3.
+func ParseDecimal(s string) (Decimal, error) {
+  digits := s
+  if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+    digits = s[1:]
+  }
+  whole, frac := digits, ""
+  if i := strings.IndexByte(digits, '.'); i != -1 {
+    whole, frac = digits[:i], digits[i+1:]
+    if frac == "" {
+      return Decimal{}, &Error{fmt.Sprintf("Invalid decimal '%s'.", s), nil, nil}
+    }
+  }
+  if whole == "" || strings.Trim(whole+frac, "0123456789") != "" {
+    return Decimal{}, &Error{fmt.Sprintf("Invalid decimal '%s'.", s), nil, nil}
+  }
+  units, _ := new(big.Int).SetString(whole+frac, 10)
+  if strings.HasPrefix(s, "-") {
+    units.Neg(units)
+  }
+  return Decimal{units, len(frac)}, nil
+}
+
+func (d Decimal) int() *big.Int {
+  if d.units == nil {
+    return new(big.Int)
+  }
+  return d.units
+}
+
+// Returns the number of digits after the decimal point.
+func (d Decimal) Scale() int {
+  return d.scale
+}
+
+// Returns the exact value of d.
+func (d Decimal) Rat() *big.Rat {
+  return new(big.Rat).SetFrac(d.int(), pow10(d.scale))
+}
+
+// Returns -1, 0, or 1 if d is less than, equal to, or greater than e.  Scale
+// does not matter, so 1.50 and 1.5 are equal.
+func (d Decimal) Cmp(e Decimal) int {
+  a, b := d.align(e)
+  return a.Cmp(b)
+}
+
+// Returns d with exactly scale digits after the decimal point, for example
+// 12.30.
+func (d Decimal) String() string {
+  units := d.int()
+  digits := new(big.Int).Abs(units).String()
+  if len(digits) <= d.scale {
+    digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+  }
+  sign := ""
+  if units.Sign() < 0 {
+    sign = "-"
+  }
+  if d.scale == 0 {
+    return sign + digits
+  }
+  return sign + digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+}
+
+// Returns d rounded to scale digits after the decimal point using mode.
+func (d Decimal) Round(scale int, mode RoundingMode) Decimal {
+  if scale < 0 {
+    panic(fmt.Sprintf("Decimal scale must not be negative, got %d.", scale))
+  }
+  if scale >= d.scale {
+    return Decimal{new(big.Int).Mul(d.int(), pow10(scale-d.scale)), scale}
+  }
+  return Decimal{roundQuo(d.int(), pow10(d.scale-scale), mode), scale}
+}
+
+// Returns the units of d and e at the larger of their two scales.
+func (d Decimal) align(e Decimal) (a, b *big.Int) {
+  if d.scale >= e.scale {
+    return d.int(), new(big.Int).Mul(e.int(), pow10(d.scale-e.scale))
+  }
+  return new(big.Int).Mul(d.int(), pow10(e.scale-d.scale)), e.int()
+}
+
+func maxScale(a, b Decimal) int {
+  if a.scale > b.scale {
+    return a.scale
+  }
+  return b.scale
+}
+
+func pow10(n int) *big.Int {
+  return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// Returns n / d rounded to an integer using mode, d must be positive.
+func roundQuo(n, d *big.Int, mode RoundingMode) *big.Int {
+  q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+  if r.Sign() == 0 {
+    return q
+  }
+  sign := int64(n.Sign())
+  half := new(big.Int).Abs(r)
+  half.Lsh(half, 1)
+  cmp := half.Cmp(d)
+  away := false
+  switch mode {
+  case RoundHalfEven:
+    away = cmp > 0 || cmp == 0 && q.Bit(0) == 1
+  case RoundHalfUp:
+    away = cmp >= 0
+  case RoundDown:
+  case RoundUp:
+    away = true
+  case RoundFloor:
+    away = sign < 0
+  case RoundCeiling:
+    away = sign > 0
+  default:
+    panic(fmt.Sprintf("Unknown rounding mode %d.", mode))
+  }
+  if away {
+    q.Add(q, big.NewInt(sign))
+  }
+  return q
+}
+
+// Returns the number of digits given as a Decimal, which must be a
+// non-negative integer.
+func decimalScale(scale Decimal) int {
+  if scale.Cmp(Decimal{}) < 0 || scale.Round(0, RoundDown).Cmp(scale) != 0 || !scale.int().IsInt64() {
+    panic(fmt.Sprintf("A scale must be a non-negative integer, got %v.", scale))
+  }
+  return int(scale.Round(0, RoundDown).int().Int64())
+}
+
+// Adds operators for fixed-point decimal math to the Context, all of which use
+// Decimal for any numerical values, and makes numeric literals parse as
+// Decimals by putting FixedPoint at the front of the parse order.  + - and *
+// are exact: the result of + and - has the larger scale of the two operands,
+// and the result of * has the sum of their scales, so 19.99 * 0.075 is
+// 1.49925.  Nothing is ever rounded implicitly, so division, which usually
+// cannot be exact, takes the scale of the result and a RoundingMode, as does
+// round, which changes the scale of a value.  The rounding modes are
+// available as the values half-even, half-up, down, up, floor, and ceiling.
+//   Functions: + - * abs neg < <= > >= ==
+//              div a b scale mode  (a / b rounded to scale digits)
+//              round a scale mode  (a rounded to scale digits)
+//   Constants: half-even half-up down up floor ceiling
+func AddDecimalContext(c *Context) {
+  order := []Type{FixedPoint}
+  for _, t := range c.parse_order {
+    if t != FixedPoint {
+      order = append(order, t)
+    }
+  }
+  c.parse_order = order
+  c.addBuiltin("+", func(a, b Decimal) Decimal { x, y := a.align(b); return Decimal{new(big.Int).Add(x, y), maxScale(a, b)} }, "Sum of two Decimals, at the larger of their scales.")
+  c.addBuiltin("-", func(a, b Decimal) Decimal { x, y := a.align(b); return Decimal{new(big.Int).Sub(x, y), maxScale(a, b)} }, "Difference of two Decimals, a - b, at the larger of their scales.")
+  c.addBuiltin("*", func(a, b Decimal) Decimal { return Decimal{new(big.Int).Mul(a.int(), b.int()), a.scale + b.scale} }, "Exact product of two Decimals, at the sum of their scales.")
+  c.addBuiltin("abs", func(a Decimal) Decimal { return Decimal{new(big.Int).Abs(a.int()), a.scale} }, "Absolute value.")
+  c.addBuiltin("neg", func(a Decimal) Decimal { return Decimal{new(big.Int).Neg(a.int()), a.scale} }, "Negation.")
+  c.addBuiltin("div", decimalDiv, "a / b rounded to scale digits after the point using mode.")
+  c.addBuiltin("round", func(a, scale Decimal, mode RoundingMode) Decimal { return a.Round(decimalScale(scale), mode) }, "a rounded to scale digits after the point using mode.")
+  c.addBuiltin("<", func(a, b Decimal) bool { return a.Cmp(b) < 0 }, "True if a < b.")
+  c.addBuiltin("<=", func(a, b Decimal) bool { return a.Cmp(b) <= 0 }, "True if a <= b.")
+  c.addBuiltin(">", func(a, b Decimal) bool { return a.Cmp(b) > 0 }, "True if a > b.")
+  c.addBuiltin(">=", func(a, b Decimal) bool { return a.Cmp(b) >= 0 }, "True if a >= b.")
+  c.addBuiltin("==", func(a, b Decimal) bool { return a.Cmp(b) == 0 }, "True if a and b are equal, regardless of scale.")
+  c.SetValue("half-even", RoundHalfEven)
+  c.SetValue("half-up", RoundHalfUp)
+  c.SetValue("down", RoundDown)
+  c.SetValue("up", RoundUp)
+  c.SetValue("floor", RoundFloor)
+  c.SetValue("ceiling", RoundCeiling)
+}
+
+func decimalDiv(a, b Decimal, scale Decimal, mode RoundingMode) Decimal {
+  if b.int().Sign() == 0 {
+    panic("Cannot divide a Decimal by zero.")
+  }
+  s := decimalScale(scale)
+  // a / b * 10^s, as an integer, is a.units * 10^(b.scale + s) divided by
+  // b.units * 10^a.scale.
+  n := new(big.Int).Mul(a.int(), pow10(b.scale+s))
+  d := new(big.Int).Mul(b.int(), pow10(a.scale))
+  if d.Sign() < 0 {
+    n.Neg(n)
+    d.Neg(d)
+  }
+  return Decimal{roundQuo(n, d, mode), s}
+}
@@ -0,0 +1,92 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+)
+
+func DecimalSpec(c gospec.Context) {
+  c.Specify("Decimals parse and print with their scale.", func() {
+    for _, s := range []string{"12.34", "-0.50", "7", "0.001", "-12"} {
+      d, err := polish.ParseDecimal(s)
+      c.Assume(err, Equals, nil)
+      c.Expect(d.String(), Equals, s)
+    }
+    for _, s := range []string{"", "1.", ".5", "1.2.3", "1e3", "abc", "-", "-+5", "+-5"} {
+      _, err := polish.ParseDecimal(s)
+      c.Expect(err, Not(Equals), nil)
+    }
+    c.Expect(polish.NewDecimal(-5, 3).String(), Equals, "-0.005")
+    c.Expect(polish.Decimal{}.String(), Equals, "0")
+  })
+  c.Specify("Rounding modes.", func() {
+    round := func(s string, mode polish.RoundingMode) string {
+      d, _ := polish.ParseDecimal(s)
+      return d.Round(0, mode).String()
+    }
+    c.Expect(round("2.5", polish.RoundHalfEven), Equals, "2")
+    c.Expect(round("3.5", polish.RoundHalfEven), Equals, "4")
+    c.Expect(round("-2.5", polish.RoundHalfEven), Equals, "-2")
+    c.Expect(round("2.5", polish.RoundHalfUp), Equals, "3")
+    c.Expect(round("-2.5", polish.RoundHalfUp), Equals, "-3")
+    c.Expect(round("2.9", polish.RoundDown), Equals, "2")
+    c.Expect(round("-2.9", polish.RoundDown), Equals, "-2")
+    c.Expect(round("2.1", polish.RoundUp), Equals, "3")
+    c.Expect(round("-2.1", polish.RoundUp), Equals, "-3")
+    c.Expect(round("-2.1", polish.RoundFloor), Equals, "-3")
+    c.Expect(round("2.1", polish.RoundCeiling), Equals, "3")
+    c.Expect(round("-2.1", polish.RoundCeiling), Equals, "-2")
+  })
+}
+
+func DecimalContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddDecimalContext(context)
+  expectDecimal := func(expression, expected string) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Interface().(polish.Decimal).String(), Equals, expected)
+  }
+  c.Specify("Arithmetic is exact.", func() {
+    expectDecimal("+ 0.1 0.2", "0.3")
+    expectDecimal("- 10 0.01", "9.99")
+    expectDecimal("* 19.99 3", "59.97")
+    expectDecimal("* 19.99 0.075", "1.49925")
+    expectDecimal("neg abs -1.50", "-1.50")
+  })
+  c.Specify("Division takes a scale and a rounding mode.", func() {
+    expectDecimal("div 10 3 2 half-even", "3.33")
+    expectDecimal("div 2 3 2 half-even", "0.67")
+    expectDecimal("div 2 3 2 down", "0.66")
+    expectDecimal("div -1.00 8 2 half-even", "-0.12")
+    expectDecimal("div 1 -8 3 half-up", "-0.125")
+    expectDecimal("div 1.5 0.5 0 half-even", "3")
+    _, err := context.Eval("div 1 0 2 half-even")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("div 1 3 1.5 half-even")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("div 1 3")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("round changes the scale.", func() {
+    expectDecimal("round * 19.99 0.075 2 half-even", "1.50")
+    expectDecimal("round 1.005 2 half-even", "1.00")
+    expectDecimal("round 1.005 2 half-up", "1.01")
+    expectDecimal("round 3 2 down", "3.00")
+  })
+  c.Specify("Comparisons ignore scale.", func() {
+    res, err := context.Eval("== 1.50 1.5")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+    res, err = context.Eval("< 0.1 0.10001")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+  })
+  c.Specify("Results are formatted with their scale.", func() {
+    s, err := context.EvalToString("+ 1.10 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(s, Equals, "3.10")
+  })
+}
@@ -0,0 +1,722 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+  "strings"
+  "time"
+  "unicode"
+)
+
+// An Engine is a strategy for evaluating expressions, see SetEngine.
+type Engine int
+const(
+  // Evaluates subexpressions with recursive calls.  This is the default.
+  Recursive Engine = iota
+
+  // Evaluates subexpressions with an explicit stack, so deeply nested
+  // expressions do not grow the goroutine stack.
+  Iterative
+)
+
+// Sets the Engine used to evaluate expressions.  Both engines produce
+// identical results, Iterative is only useful for very deeply nested
+// expressions, such as machine-generated ones with thousands of operators.
+func (c *Context) SetEngine(engine Engine) {
+  c.engine = engine
+}
+
+// The state of a single evaluation, kept separate from the Context so that
+// evaluations do not interfere with each other.
+type evaluation struct {
+  c *Context
+
+  // Terms that have not been evaluated yet.
+  terms []string
+
+  // Values that are only visible to this evaluation, these take precedence
+  // over values in the Context.
+  bindings map[string]reflect.Value
+
+  // Results of constant folding, see Expr.
+  consts map[string][]reflect.Value
+
+  // Only set during a call to EvalWithStats.
+  stats *Stats
+  depth int
+
+  // Set by EvalTimeout, no more functions are called once it has passed.
+  deadline time.Time
+
+  // Set by EvalBytes, the expression to use in error messages in place of the
+  // one passed to evaluate.
+  source []byte
+
+  // Set by EvalAll, terms left over after the expression are returned to the
+  // caller rather than being an error under strict arity.
+  keep_leftover bool
+}
+
+// Terms that are handled by the evaluator itself rather than being looked up,
+// these cannot be used as the names of functions or values.
+var special_forms = map[string]bool{
+  "apply":   true,
+  "bind":    true,
+  "fold":    true,
+  "map":     true,
+  "nth":     true,
+  "tuple":   true,
+  "untuple": true,
+}
+
+// A term whose arguments are still being evaluated.  Lists are frames whose
+// term is "[", and special forms are frames whose term is the name of the form.
+type frame struct {
+  term string
+  f    function
+  args []reflect.Value
+
+  // Number of subexpressions evaluated so far, and how many of those produced
+  // no values.
+  subs  int
+  empty int
+
+  // For bind, the names given to the values of its first subexpression.
+  names []string
+
+  // For fold and map, the name of the function they apply, which is stored
+  // in f.
+  ref string
+
+  // For tuple and untuple, the number of values in the Tuple.
+  count int
+}
+
+// Returns a value bound for just this evaluation, or else a value from the
+// Context.
+func (ev *evaluation) lookupValue(name string) (reflect.Value, bool) {
+  if val, ok := ev.bindings[name]; ok {
+    return val, true
+  }
+  return ev.c.lookupValue(name)
+}
+
+// Evaluates the whole expression with the configured Engine.  With strict
+// arity, terms left over after the expression are an error unless they are
+// being kept for EvalAll.
+func (ev *evaluation) run() ([]reflect.Value, error) {
+  first := ""
+  if len(ev.terms) > 0 {
+    first = ev.terms[0]
+  }
+  var vs []reflect.Value
+  var err error
+  if ev.c.engine == Iterative {
+    vs, err = ev.iterEval()
+  } else {
+    vs, err = ev.subEval("", 0)
+  }
+  if err == nil && ev.c.strict_arity && !ev.keep_leftover && len(ev.terms) > 0 {
+    if _, ok := ev.c.lookupFunc(first); !ok && !special_forms[first] && first != "[" {
+      return nil, &Error{fmt.Sprintf("'%s' is a value, not a function, so it cannot take '%s' or any of the terms after it as arguments.", first, ev.terms[0]), nil, nil}
+    }
+    return nil, &Error{fmt.Sprintf("The expression ends before '%s', which is not used, along with any terms after it.", ev.terms[0]), nil, nil}
+  }
+  return vs, err
+}
+
+// Counts a term evaluated at the given depth.
+func (ev *evaluation) count(depth int) {
+  ev.stats.Nodes++
+  if depth > ev.stats.MaxDepth {
+    ev.stats.MaxDepth = depth
+  }
+}
+
+// Starts evaluating a term that has just been removed from ev.terms.  parent
+// is the function whose argument is being evaluated, if any, and arg is the
+// index of that argument, these are only used to make error messages more
+// helpful.  If the term needs arguments then a frame is returned, otherwise its
+// values are.
+func (ev *evaluation) open(term string, parent string, arg int) ([]reflect.Value, *frame, error) {
+  c := ev.c
+  if vs, ok := ev.consts[term]; ok {
+    return vs, nil, nil
+  }
+  switch term {
+  case "[", "nth", "apply":
+    return nil, &frame{term: term}, nil
+  case "]":
+    return nil, nil, &Error{"Found ']' without a matching '['.", nil, nil}
+  case "bind":
+    names, rest, err := c.bindNames(ev.terms)
+    if err != nil {
+      return nil, nil, err
+    }
+    ev.terms = rest
+    return nil, &frame{term: term, names: names}, nil
+  case "tuple", "untuple":
+    count, err := tupleCount(term, ev.terms)
+    if err != nil {
+      return nil, nil, err
+    }
+    ev.terms = ev.terms[1:]
+    return nil, &frame{term: term, count: count}, nil
+  case "fold", "map":
+    if len(ev.terms) == 0 {
+      return nil, nil, &Error{fmt.Sprintf("Unexpected end of expression: '%s' needs a function.", term), nil, nil}
+    }
+    ref := ev.terms[0]
+    ev.terms = ev.terms[1:]
+    inputs := 2
+    if term == "map" {
+      inputs = 1
+    }
+    f, err := c.referencedFunc(term, ref, inputs)
+    if err != nil {
+      return nil, nil, err
+    }
+    return nil, &frame{term: term, f: f, ref: ref}, nil
+  }
+  if f, ok := c.lookupFunc(term); ok {
+    if c.pure_only && !f.pure {
+      return nil, nil, &Error{fmt.Sprintf("Function '%s' is not pure and only pure functions are allowed.", term), nil, nil}
+    }
+    if f.token {
+      return ev.callToken(term, f)
+    }
+    return nil, &frame{term: term, f: f}, nil
+  }
+  if val, ok := ev.lookupValue(term); ok {
+    if ev.stats != nil {
+      ev.stats.Lookups++
+    }
+    if c.value_hook != nil {
+      if _, bound := ev.bindings[term]; !bound {
+        c.value_hook(term)
+      }
+    }
+    return []reflect.Value{val}, nil, nil
+  }
+  if c.disabled[term] {
+    return nil, nil, c.disabledError(term)
+  }
+  if len(c.funcs) == 0 && parent == "" && len(ev.terms) > 0 && looksLikeOperator(term) {
+    return nil, nil, &Error{fmt.Sprintf("Unable to parse term: unknown function '%s'%s", term, no_funcs_hint), nil, nil}
+  }
+  val, _, err := c.parseLiteral(term)
+  if err != nil {
+    return nil, nil, err
+  }
+  if val == (reflect.Value{}) && c.resolver != nil {
+    if rval, ok := c.resolver(term); ok {
+      val = rval
+    }
+  }
+  if val == (reflect.Value{}) {
+    val = c.default_value
+  }
+  if val == (reflect.Value{}) {
+    switch {
+    case parent != "":
+      return nil, nil, &Error{fmt.Sprintf("Unable to parse term: unknown value '%s' for argument %d of '%s'", term, arg+1, parent), nil, nil}
+    case len(ev.terms) > 0:
+      hint := ""
+      if len(c.funcs) == 0 {
+        hint = no_funcs_hint
+      }
+      return nil, nil, &Error{fmt.Sprintf("Unable to parse term: unknown function '%s'%s", term, hint), nil, nil}
+    }
+    return nil, nil, &Error{fmt.Sprintf("Unable to parse term: unknown value '%s'", term), nil, nil}
+  }
+  return []reflect.Value{val}, nil, nil
+}
+
+// Added to errors about unknown functions when a Context has no functions,
+// which usually means that a context such as AddFloat64MathContext was not
+// added.
+const no_funcs_hint = ", no functions have been added to the Context, was a context such as AddFloat64MathContext or AddIntMathContext left out?"
+
+// Returns whether term is made up entirely of punctuation and symbols, like
+// + or &&.
+func looksLikeOperator(term string) bool {
+  for _, r := range term {
+    if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+      return false
+    }
+  }
+  return term != ""
+}
+
+// Reads the parenthesized names that follow bind from the start of terms,
+// returning them along with the terms after the closing parenthesis.  The
+// parentheses may be separate terms or attached to the first and last names.
+func (c *Context) bindNames(terms []string) ([]string, []string, error) {
+  if len(terms) == 0 || !strings.HasPrefix(terms[0], "(") {
+    return nil, nil, &Error{"bind must be followed by a list of names in parentheses.", nil, nil}
+  }
+  var names []string
+  for i, term := range terms {
+    if i == 0 {
+      term = term[1:]
+    }
+    closed := strings.HasSuffix(term, ")")
+    if closed {
+      term = term[:len(term)-1]
+    }
+    if strings.ContainsAny(term, "()") {
+      return nil, nil, &Error{fmt.Sprintf("Unexpected parenthesis in the names of bind: '%s'.", terms[i]), nil, nil}
+    }
+    if term != "" {
+      if _, ok := c.lookupFunc(term); ok {
+        return nil, nil, &Error{fmt.Sprintf("Cannot bind the name '%s', it is already a function.", term), nil, nil}
+      }
+      if special_forms[term] || term == "[" || term == "]" {
+        return nil, nil, &Error{fmt.Sprintf("Cannot bind the name '%s', it is reserved.", term), nil, nil}
+      }
+      names = append(names, term)
+    }
+    if closed {
+      return names, terms[i+1:], nil
+    }
+  }
+  return nil, nil, &Error{"Found '(' without a matching ')' in the names of bind.", nil, nil}
+}
+
+// Binds names to vs for the rest of the evaluation.  The bindings are copied
+// rather than modified since they may belong to the caller, see Expr.EvalWith.
+func (ev *evaluation) bind(names []string, vs []reflect.Value) error {
+  if len(names) != len(vs) {
+    return &Error{fmt.Sprintf("bind was given %d name(s) but its expression produced %d value(s).", len(names), len(vs)), nil, nil}
+  }
+  bindings := make(map[string]reflect.Value, len(ev.bindings)+len(names))
+  for name, val := range ev.bindings {
+    bindings[name] = val
+  }
+  for i, name := range names {
+    bindings[name] = vs[i]
+  }
+  ev.bindings = bindings
+  return nil
+}
+
+// Adds the values of one of fr's subexpressions to it.
+func (ev *evaluation) accept(fr *frame, vs []reflect.Value) error {
+  fr.subs++
+  if len(vs) == 0 {
+    fr.empty++
+  }
+  if fr.term == "bind" && fr.subs == 1 {
+    return ev.bind(fr.names, vs)
+  }
+  if fr.term == "nth" && fr.subs == 1 && len(vs) != 1 {
+    return &Error{fmt.Sprintf("The index given to 'nth' must be a single value, but it produced %d value(s).", len(vs)), nil, nil}
+  }
+  if fr.term == "apply" && fr.subs == 1 {
+    f, err := appliedFunc(vs)
+    fr.f = f
+    return err
+  }
+  fr.args = append(fr.args, vs...)
+  return nil
+}
+
+// Returns whether fr has all of its arguments.  For a list this consumes the
+// closing ']' if it is the next term.
+func (ev *evaluation) complete(fr *frame) bool {
+  switch fr.term {
+  case "[":
+    if len(ev.terms) > 0 && ev.terms[0] == "]" {
+      ev.terms = ev.terms[1:]
+      return true
+    }
+    return false
+  case "bind", "fold", "nth":
+    return fr.subs == 2
+  case "map", "untuple":
+    return fr.subs == 1
+  case "tuple":
+    return len(fr.args) >= fr.count
+  case "apply":
+    return fr.subs > 0 && len(fr.args) >= fr.f.num
+  }
+  return len(fr.args) >= fr.f.num
+}
+
+// Returns the error for a frame that is not complete when there are no terms
+// left.
+func (ev *evaluation) incomplete(fr *frame) error {
+  switch fr.term {
+  case "[":
+    return &Error{"Found '[' without a matching ']'.", nil, nil}
+  case "bind":
+    if fr.subs == 0 {
+      return &Error{"Unexpected end of expression: 'bind' needs an expression to bind and a body.", nil, nil}
+    }
+    return &Error{"Unexpected end of expression: 'bind' needs a body.", nil, nil}
+  case "fold":
+    if fr.subs == 0 {
+      return &Error{"Unexpected end of expression: 'fold' needs an initial value and a list.", nil, nil}
+    }
+    return &Error{"Unexpected end of expression: 'fold' needs a list.", nil, nil}
+  case "map":
+    return &Error{"Unexpected end of expression: 'map' needs a list.", nil, nil}
+  case "nth":
+    if fr.subs == 0 {
+      return &Error{"Unexpected end of expression: 'nth' needs an index and an expression.", nil, nil}
+    }
+    return &Error{"Unexpected end of expression: 'nth' needs an expression.", nil, nil}
+  case "tuple":
+    return &Error{fmt.Sprintf("Unexpected end of expression: 'tuple %d' needs %d more value(s).", fr.count, fr.count-len(fr.args)), nil, nil}
+  case "untuple":
+    return &Error{fmt.Sprintf("Unexpected end of expression: 'untuple %d' needs an expression.", fr.count), nil, nil}
+  case "apply":
+    if fr.subs == 0 {
+      return &Error{"Unexpected end of expression: 'apply' needs a func and its arguments.", nil, nil}
+    }
+  }
+  msg := fmt.Sprintf("Unexpected end of expression: '%s' needs %d more argument(s), its arguments so far produced %d value(s).", fr.term, fr.f.num-len(fr.args), len(fr.args))
+  if fr.empty > 0 {
+    msg += fmt.Sprintf("  %d of its %d argument subexpression(s) produced no values.", fr.empty, fr.subs)
+  }
+  return &Error{msg, nil, nil}
+}
+
+// Returns the value in vs at index, which counts from 0 and must be an integer.
+func nth(index reflect.Value, vs []reflect.Value) ([]reflect.Value, error) {
+  var i int64
+  switch index.Kind() {
+  case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+    i = index.Int()
+  case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+    if index.Uint() > uint64(len(vs)) {
+      i = int64(len(vs))
+    } else {
+      i = int64(index.Uint())
+    }
+  default:
+    return nil, &Error{fmt.Sprintf("The index given to 'nth' must be an integer, not a %v.", typeOf(index)), nil, nil}
+  }
+  if i < 0 || i >= int64(len(vs)) {
+    return nil, &Error{fmt.Sprintf("Index %v given to 'nth' is out of range for an expression that produced %d value(s).", index, len(vs)), nil, nil}
+  }
+  return []reflect.Value{vs[i]}, nil
+}
+
+// Returns the error for running out of terms when the next term is needed as
+// an argument of parent, or as the whole expression if parent is empty.
+// Frames that are waiting for arguments report this with incomplete instead,
+// which knows how many arguments are missing.
+func exhausted(parent string) error {
+  if parent == "" {
+    return &Error{"Cannot evaluate an empty expression.", nil, nil}
+  }
+  return &Error{fmt.Sprintf("Unexpected end of expression: '%s' needs more arguments.", parent), nil, nil}
+}
+
+// Produces the values of a complete frame.
+func (ev *evaluation) finish(fr *frame) ([]reflect.Value, error) {
+  switch fr.term {
+  case "[":
+    return makeList(fr.args)
+  case "bind":
+    return fr.args, nil
+  case "fold":
+    return ev.fold(fr)
+  case "map":
+    return ev.mapList(fr)
+  case "nth":
+    return nth(fr.args[0], fr.args[1:])
+  case "tuple", "untuple":
+    return finishTuple(fr)
+  }
+  args := fr.args
+  var remaining []reflect.Value
+  if len(args) > fr.f.num {
+    if ev.c.strict_arity {
+      return nil, &Error{fmt.Sprintf("Function '%s' takes %d argument(s) but was given %d values.", fr.term, fr.f.num, len(args)), nil, nil}
+    }
+    remaining = args[fr.f.num:]
+    args = args[0:fr.f.num]
+  }
+  vs, err := ev.call(fr.term, fr.f, args)
+  if err != nil {
+    return nil, err
+  }
+  return append(vs, remaining...), nil
+}
+
+// Calls the function f, whose name is term, supplying the Context first if f
+// was added with AddContextFunc, and records the call.
+func (ev *evaluation) call(term string, f function, args []reflect.Value) ([]reflect.Value, error) {
+  if !ev.deadline.IsZero() && time.Now().After(ev.deadline) {
+    return nil, &Error{fmt.Sprintf("Stopped before calling '%s' because the deadline passed.", term), nil, ErrTimeout}
+  }
+  call := args
+  if f.ctx {
+    if ev.c.nesting >= ev.c.max_nesting {
+      return nil, &Error{fmt.Sprintf("Calling '%s' would nest more than %d context function calls.", term, ev.c.max_nesting), nil, nil}
+    }
+    nested := *ev.c
+    nested.nesting++
+    call = append([]reflect.Value{reflect.ValueOf(&nested)}, args...)
+  }
+  if err := checkArgs(term, f, call); err != nil {
+    return nil, err
+  }
+  vs := f.f.Call(call)
+  for i := range vs {
+    // A function that returns an interface{} produces whatever it put in it,
+    // so that the value can be passed to functions that take its real type.
+    if vs[i].Kind() == reflect.Interface && vs[i].NumMethod() == 0 {
+      vs[i] = vs[i].Elem()
+    }
+  }
+  if ev.stats != nil {
+    ev.stats.Calls++
+  }
+  if ev.c.tracer != nil {
+    ev.c.tracer(term, args, vs)
+  }
+  return vs, nil
+}
+
+// Calls the token function f, whose name is term, on the remaining terms and
+// removes the ones it consumed, recording the call like call does.
+func (ev *evaluation) callToken(term string, f function) ([]reflect.Value, *frame, error) {
+  if !ev.deadline.IsZero() && time.Now().After(ev.deadline) {
+    return nil, nil, &Error{fmt.Sprintf("Stopped before calling '%s' because the deadline passed.", term), nil, ErrTimeout}
+  }
+  vs, consumed, err := callTokenFunc(term, f, ev.terms)
+  if err != nil {
+    return nil, nil, err
+  }
+  ev.terms = ev.terms[consumed:]
+  if ev.stats != nil {
+    ev.stats.Calls++
+  }
+  if ev.c.tracer != nil {
+    ev.c.tracer(term, nil, vs)
+  }
+  return vs, nil, nil
+}
+
+// Checks that each argument can be passed to the corresponding parameter of f,
+// so that mistakes are reported in terms of the expression rather than as a
+// panic from reflect.  Any value can be passed to an interface{} parameter,
+// but a parameter with a non-empty interface type only accepts values that
+// implement it.  An invalid Value, such as one made by SetValue(name, nil), is
+// replaced by the zero value of a parameter that can be nil.
+func checkArgs(term string, f function, args []reflect.Value) error {
+  typ := f.f.Type()
+  if typ.IsVariadic() {
+    return nil
+  }
+  first := 0
+  if f.ctx {
+    first = 1
+  }
+  for i := first; i < len(args); i++ {
+    param := typ.In(i)
+    if !args[i].IsValid() {
+      switch param.Kind() {
+      case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+        args[i] = reflect.Zero(param)
+        continue
+      }
+      return &Error{fmt.Sprintf("Argument %d of '%s' is nil, which cannot be used as %s.", i-first+1, term, withArticle(param)), nil, nil}
+    }
+    if !args[i].Type().AssignableTo(param) {
+      if param.Kind() == reflect.Interface {
+        return &Error{fmt.Sprintf("Argument %d of '%s' is a %v, which does not implement %v.", i-first+1, term, args[i].Type(), param), nil, nil}
+      }
+      return &Error{fmt.Sprintf("Argument %d of '%s' is %s, which cannot be used as %s.%s", i-first+1, term, withArticle(args[i].Type()), withArticle(param), numericHint(args[i].Type(), param)), nil, nil}
+    }
+  }
+  return nil
+}
+
+// Returns the name of t preceded by "a" or "an", whichever reads correctly.
+func withArticle(t reflect.Type) string {
+  name := fmt.Sprint(t)
+  if strings.IndexAny(name[:1], "aeiou") == 0 && !strings.HasPrefix(name, "uint") {
+    return "an " + name
+  }
+  return "a " + name
+}
+
+// Returns the function named by the term following a special form such as
+// fold, which must take the given number of arguments and return one value.
+func (c *Context) referencedFunc(form, name string, inputs int) (function, error) {
+  f, ok := c.lookupFunc(name)
+  if !ok && c.disabled[name] {
+    return function{}, c.disabledError(name)
+  }
+  if !ok {
+    return function{}, &Error{fmt.Sprintf("'%s' needs the name of a function, not '%s'.", form, name), nil, nil}
+  }
+  if f.num != inputs || f.f.Type().NumOut() != 1 {
+    return function{}, &Error{fmt.Sprintf("'%s' needs a function that takes %d argument(s) and returns one value, '%s' does not.", form, inputs, name), nil, nil}
+  }
+  if c.pure_only && !f.pure {
+    return function{}, &Error{fmt.Sprintf("Function '%s' is not pure and only pure functions are allowed.", name), nil, nil}
+  }
+  return f, nil
+}
+
+// Reduces the list given to fold from left to right, starting with the
+// initial value.
+func (ev *evaluation) fold(fr *frame) ([]reflect.Value, error) {
+  if len(fr.args) != 2 {
+    return nil, &Error{fmt.Sprintf("'fold' needs an initial value and a list, but was given %d value(s).", len(fr.args)), nil, nil}
+  }
+  acc, list := fr.args[0], fr.args[1]
+  if !list.IsValid() {
+    return nil, &Error{"'fold' needs a list, but was given nil.", nil, nil}
+  }
+  if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+    return nil, &Error{fmt.Sprintf("'fold' needs a list, but was given a %v.", list.Type()), nil, nil}
+  }
+  params := fr.f.params()
+  if !list.Type().Elem().AssignableTo(params[1]) {
+    return nil, &Error{fmt.Sprintf("'fold' cannot pass elements of a %v to '%s', which takes a %v.", list.Type(), fr.ref, params[1]), nil, nil}
+  }
+  for i := 0; i < list.Len(); i++ {
+    // A nil initial value is left for call to check against the parameter.
+    if acc.IsValid() && !acc.Type().AssignableTo(params[0]) {
+      return nil, &Error{fmt.Sprintf("'fold' cannot pass a %v to '%s', which takes a %v.", acc.Type(), fr.ref, params[0]), nil, nil}
+    }
+    vs, err := ev.call(fr.ref, fr.f, []reflect.Value{acc, list.Index(i)})
+    if err != nil {
+      return nil, err
+    }
+    acc = vs[0]
+  }
+  return []reflect.Value{acc}, nil
+}
+
+// Collects values into a slice.  Every value must have the same type, and the
+// result is a slice of that type, so "[1.0 2.0]" is a []float64.
+func makeList(elems []reflect.Value) ([]reflect.Value, error) {
+  if len(elems) == 0 {
+    return nil, &Error{"Cannot determine the type of an empty list.", nil, nil}
+  }
+  typ := elems[0].Type()
+  list := reflect.MakeSlice(reflect.SliceOf(typ), len(elems), len(elems))
+  for i, elem := range elems {
+    if elem.Type() != typ {
+      return nil, &Error{fmt.Sprintf("List elements must all have the same type, found %v and %v.", typ, elem.Type()), nil, nil}
+    }
+    list.Index(i).Set(elem)
+  }
+  return []reflect.Value{list}, nil
+}
+
+// Evaluates the next complete term recursively.
+func (ev *evaluation) subEval(parent string, arg int) ([]reflect.Value, error) {
+  if len(ev.terms) == 0 {
+    return nil, exhausted(parent)
+  }
+  term := ev.terms[0]
+  ev.terms = ev.terms[1:]
+  if ev.stats != nil {
+    ev.depth++
+    ev.count(ev.depth)
+    defer func() { ev.depth-- }()
+  }
+  vs, fr, err := ev.open(term, parent, arg)
+  if err != nil || fr == nil {
+    return vs, err
+  }
+  for !ev.complete(fr) {
+    if len(ev.terms) == 0 {
+      return nil, ev.incomplete(fr)
+    }
+    results, err := ev.subEval(fr.term, len(fr.args))
+    if err != nil {
+      return nil, err
+    }
+    if err := ev.accept(fr, results); err != nil {
+      return nil, err
+    }
+  }
+  return ev.finish(fr)
+}
+
+// Evaluates the next complete term using an explicit stack of frames instead
+// of recursion.  This must behave exactly like subEval.
+func (ev *evaluation) iterEval() ([]reflect.Value, error) {
+  var stack []*frame
+  for {
+    parent, arg := "", 0
+    if len(stack) > 0 {
+      top := stack[len(stack)-1]
+      if len(ev.terms) == 0 {
+        return nil, ev.incomplete(top)
+      }
+      parent, arg = top.term, len(top.args)
+    } else if len(ev.terms) == 0 {
+      return nil, exhausted("")
+    }
+    term := ev.terms[0]
+    ev.terms = ev.terms[1:]
+    if ev.stats != nil {
+      ev.count(len(stack) + 1)
+    }
+    vs, fr, err := ev.open(term, parent, arg)
+    if err != nil {
+      return nil, err
+    }
+    if fr != nil {
+      stack = append(stack, fr)
+    } else if len(stack) == 0 {
+      return vs, nil
+    } else if err := ev.accept(stack[len(stack)-1], vs); err != nil {
+      return nil, err
+    }
+    for len(stack) > 0 && ev.complete(stack[len(stack)-1]) {
+      top := stack[len(stack)-1]
+      stack = stack[:len(stack)-1]
+      vs, err = ev.finish(top)
+      if err != nil {
+        return nil, err
+      }
+      if len(stack) == 0 {
+        return vs, nil
+      }
+      if err := ev.accept(stack[len(stack)-1], vs); err != nil {
+        return nil, err
+      }
+    }
+  }
+}
+
+// Applies the function given to map to each element of a list, producing a
+// list of the results.  The result is a slice of the function's output type,
+// so it is well defined even for an empty list.
+func (ev *evaluation) mapList(fr *frame) ([]reflect.Value, error) {
+  if len(fr.args) != 1 {
+    return nil, &Error{fmt.Sprintf("'map' needs a list, but was given %d value(s).", len(fr.args)), nil, nil}
+  }
+  list := fr.args[0]
+  if !list.IsValid() {
+    return nil, &Error{"'map' needs a list, but was given nil.", nil, nil}
+  }
+  if list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+    return nil, &Error{fmt.Sprintf("'map' needs a list, but was given a %v.", list.Type()), nil, nil}
+  }
+  typ := fr.f.f.Type()
+  param := fr.f.params()[0]
+  if !list.Type().Elem().AssignableTo(param) {
+    return nil, &Error{fmt.Sprintf("'map' cannot pass elements of a %v to '%s', which takes a %v.", list.Type(), fr.ref, param), nil, nil}
+  }
+  result := reflect.MakeSlice(reflect.SliceOf(typ.Out(0)), list.Len(), list.Len())
+  for i := 0; i < list.Len(); i++ {
+    vs, err := ev.call(fr.ref, fr.f, []reflect.Value{list.Index(i)})
+    if err != nil {
+      return nil, err
+    }
+    result.Index(i).Set(vs[0])
+  }
+  return []reflect.Value{result}, nil
+}
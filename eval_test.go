@@ -0,0 +1,379 @@
+package polish_test
+
+import (
+  "fmt"
+  "math"
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "math/rand"
+  "reflect"
+  "strings"
+)
+
+func makeEngineContext(engine polish.Engine) *polish.Context {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("rev3", func(a, b, c int) (int, int, int) { return c, b, a })
+  context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+  context.AddFunc("makeZero", func() {})
+  context.AddFunc("len", func(v []int) int { return len(v) })
+  context.SetValue("x", 7)
+  context.SetEngine(engine)
+  return context
+}
+
+func formatResults(res []interface{}) string {
+  return fmt.Sprint(res...)
+}
+
+func EngineSpec(c gospec.Context) {
+  recursive := makeEngineContext(polish.Recursive)
+  iterative := makeEngineContext(polish.Iterative)
+  c.Specify("Both engines agree on random expressions.", func() {
+    vocabulary := []string{
+      "+", "-", "*", "/", "^", "abs", "<", "==", "rev3", "makeTwo", "makeZero",
+      "len", "[", "]", "x", "0", "1", "2", "-3", "1.5", "foo",
+    }
+    r := rand.New(rand.NewSource(1))
+    for i := 0; i < 5000; i++ {
+      terms := make([]string, r.Intn(12))
+      for j := range terms {
+        terms[j] = vocabulary[r.Intn(len(vocabulary))]
+      }
+      expression := strings.Join(terms, " ")
+      res1, stats1, err1 := recursive.EvalWithStats(expression)
+      res2, stats2, err2 := iterative.EvalWithStats(expression)
+      c.Expect(err1 == nil, Equals, err2 == nil)
+      if err1 != nil && err2 != nil {
+        c.Expect(err2.Error(), Equals, err1.Error())
+        continue
+      }
+      var vals1, vals2 []interface{}
+      for _, v := range res1 {
+        vals1 = append(vals1, v.Interface())
+      }
+      for _, v := range res2 {
+        vals2 = append(vals2, v.Interface())
+      }
+      c.Expect(formatResults(vals2), Equals, formatResults(vals1))
+      c.Expect(stats2, Equals, stats1)
+    }
+  })
+  c.Specify("The iterative engine handles very deep expressions.", func() {
+    depth := 100000
+    expression := strings.Repeat("+ 1 ", depth) + "0"
+    res, stats, err := iterative.EvalWithStats(expression)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, depth)
+    c.Expect(stats.MaxDepth, Equals, depth+1)
+  })
+}
+
+func BindSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := makeEngineContext(engine)
+    context.AddFunc("rev5", func(a, b, c, d, e int) (int, int, int, int, int) { return e, d, c, b, a })
+    evalInts := func(expression string) []int {
+      res, err := context.Eval(expression)
+      c.Assume(err, Equals, nil)
+      var ints []int
+      for _, v := range res {
+        ints = append(ints, int(v.Int()))
+      }
+      return ints
+    }
+    c.Specify("bind names the results of an expression for its body.", func() {
+      c.Expect(evalInts("bind (a b c) rev3 1 2 3 - a c"), ContainsInOrder, []int{2})
+      c.Expect(evalInts("bind ( a b c d e ) rev5 1 2 3 4 5 * a e"), ContainsInOrder, []int{5})
+      c.Expect(evalInts("bind (a) 4 bind (b) * a a + a b"), ContainsInOrder, []int{20})
+    })
+    c.Specify("Bindings last for the rest of the evaluation.", func() {
+      c.Expect(evalInts("+ bind (a) 3 a a"), ContainsInOrder, []int{6})
+    })
+    c.Specify("The body of bind may produce any number of values.", func() {
+      c.Expect(evalInts("bind (a b) makeTwo rev3 a b x"), ContainsInOrder, []int{7, 2, 1})
+    })
+    c.Specify("Bindings shadow values in the Context.", func() {
+      c.Expect(evalInts("bind (x) 1 x"), ContainsInOrder, []int{1})
+      c.Expect(evalInts("x"), ContainsInOrder, []int{7})
+    })
+    c.Specify("Mismatched counts are errors.", func() {
+      _, err := context.Eval("bind (a b) rev3 1 2 3 a")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("bind (a b) 1 a")
+      c.Expect(err, Not(Equals), nil)
+    })
+    c.Specify("Malformed binds are errors.", func() {
+      for _, expression := range []string{
+        "bind a 1 a",
+        "bind (a 1 a",
+        "bind (a) 1",
+        "bind (a)",
+        "bind (+) 1 2",
+        "bind (a (b)) 1 2 a",
+      } {
+        _, err := context.Eval(expression)
+        c.Expect(err, Not(Equals), nil)
+      }
+    })
+  }
+  c.Specify("bind is a reserved name.", func() {
+    context := polish.MakeContext()
+    c.Expect(context.AddFunc("bind", func() int { return 1 }), Not(Equals), nil)
+    c.Expect(context.SetValue("bind", 1), Not(Equals), nil)
+  })
+  c.Specify("bind is parsed and can be compiled.", func() {
+    context := makeEngineContext(polish.Recursive)
+    n, err := context.Parse("bind (a b) makeTwo + a b")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.String(), Equals, "bind ( a b ) makeTwo + a b")
+    c.Expect(len(n.Children), Equals, 3)
+    _, err = context.Parse("bind (a b) 1 a")
+    c.Expect(err, Not(Equals), nil)
+    context.SetConstantFolding(true)
+    expr, err := context.Compile("bind (x) + 1 2 * x y", "y")
+    c.Assume(err, Equals, nil)
+    res, err := expr.EvalWith(map[string]reflect.Value{"y": reflect.ValueOf(5)})
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 15)
+  })
+}
+
+func FoldSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.AddFunc("count", func(n int, x float64) int { return n + 1 })
+    context.AddFunc("makeTwo", func() (float64, float64) { return 1, 2 })
+    context.SetEngine(engine)
+    c.Specify("fold reduces a list from left to right.", func() {
+      res, err := context.Eval("fold + 0.0 [1.0 2.0 3.0]")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(res[0].Float(), Equals, 6.0)
+      res, err = context.Eval("fold - 10.0 [1.0 2.0 3.0]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Float(), Equals, 4.0)
+    })
+    c.Specify("fold can be nested in other expressions.", func() {
+      res, err := context.Eval("* 2.0 fold * 1.0 [makeTwo + 1.0 2.0]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Float(), Equals, 12.0)
+    })
+    c.Specify("The accumulator and elements may have different types.", func() {
+      res, err := context.Eval("fold count 0 [1.5 2.5]")
+      c.Assume(err, Equals, nil)
+      c.Expect(int(res[0].Int()), Equals, 2)
+    })
+    c.Specify("fold can apply a context function.", func() {
+      context.AddContextFunc("scaled", func(c *polish.Context, acc, x float64) float64 { return acc + 10*x })
+      res, err := context.Eval("fold scaled 1.0 [1.0 2.0]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Float(), Equals, 31.0)
+      _, err = context.Eval("fold scaled 1 [1.0 2.0]")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(strings.Contains(err.Error(), "'scaled', which takes a float64"), Equals, true)
+    })
+    c.Specify("nil lists and initial values are errors.", func() {
+      context.SetValue("nothing", nil)
+      _, err := context.Eval("fold + 0.0 nothing")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, "'fold' needs a list, but was given nil.")
+      _, err = context.Eval("fold + nothing [1.0]")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, "Argument 1 of '+' is nil, which cannot be used as a float64.")
+    })
+    c.Specify("Mistakes are errors.", func() {
+      for _, expression := range []string{
+        "fold",
+        "fold +",
+        "fold + 0.0",
+        "fold ln 0.0 [1.0]",
+        "fold pi 0.0 [1.0]",
+        "fold + 0.0 1.0",
+        "fold + 0 [1.0]",
+        "fold + 0.0 [1 2]",
+        "fold + makeTwo [1.0]",
+      } {
+        _, err := context.Eval(expression)
+        c.Expect(err, Not(Equals), nil)
+      }
+    })
+  }
+  c.Specify("fold is parsed as a single node.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    n, err := context.Parse("+ 1.0 fold + 0.0 [1.0 2.0]")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.String(), Equals, "+ 1.0 fold + 0.0 [ 1.0 2.0 ]")
+    c.Expect(len(n.Children), Equals, 2)
+    c.Expect(context.Check("fold + 0.0 [1.0 2.0]"), Equals, nil)
+    c.Expect(context.Check("fold ln 0.0 [1.0 2.0]"), Not(Equals), nil)
+  })
+}
+
+func MapSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.AddFunc("sqrt", math.Sqrt)
+    context.AddFunc("round", func(x float64) int { return int(math.Floor(x + 0.5)) })
+    context.AddFunc("sum", func(v []float64) float64 { s := 0.0; for _, x := range v { s += x }; return s })
+    context.SetEngine(engine)
+    c.Specify("map applies a function to each element of a list.", func() {
+      res, err := context.Eval("map sqrt [1.0 4.0 9.0]")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(res[0].Interface(), ContainsInOrder, []float64{1, 2, 3})
+    })
+    c.Specify("The result has the function's output type.", func() {
+      res, err := context.Eval("map round [1.2 2.7]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Interface(), ContainsInOrder, []int{1, 3})
+    })
+    c.Specify("map can be nested in other expressions.", func() {
+      res, err := context.Eval("sum map abs map sqrt [1.0 4.0]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Float(), Equals, 3.0)
+      res, err = context.Eval("fold + 0.0 map sqrt [4.0 9.0]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Float(), Equals, 5.0)
+    })
+    c.Specify("Mistakes are errors.", func() {
+      for _, expression := range []string{
+        "map",
+        "map sqrt",
+        "map + [1.0]",
+        "map pi [1.0]",
+        "map sqrt 1.0",
+        "map sqrt [1 4]",
+      } {
+        _, err := context.Eval(expression)
+        c.Expect(err, Not(Equals), nil)
+      }
+    })
+    c.Specify("nil lists are errors.", func() {
+      context.SetValue("nothing", nil)
+      _, err := context.Eval("map abs nothing")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, "'map' needs a list, but was given nil.")
+    })
+    c.Specify("map can apply a context function.", func() {
+      context.AddContextFunc("twice", func(c *polish.Context, x float64) float64 { return 2 * x })
+      res, err := context.Eval("map twice [1.0 2.0]")
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Interface(), ContainsInOrder, []float64{2, 4})
+      _, err = context.Eval("map twice [1 2]")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(strings.Contains(err.Error(), "'twice', which takes a float64"), Equals, true)
+    })
+    c.Specify("Type errors name the function and types.", func() {
+      _, err := context.Eval("map sqrt [1 4]")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(strings.Contains(err.Error(), "'sqrt', which takes a float64"), Equals, true)
+    })
+  }
+  c.Specify("map is parsed as a single node.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    n, err := context.Parse("map abs [1.0 -2.0]")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.String(), Equals, "map abs [ 1.0 -2.0 ]")
+    c.Expect(context.Check("map + [1.0]"), Not(Equals), nil)
+  })
+}
+
+func NthSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := makeEngineContext(engine)
+    c.Specify("nth keeps one of the values of its expression.", func() {
+      res, err := context.Eval("nth 0 rev3 1 2 3")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(int(res[0].Int()), Equals, 3)
+      res, err = context.Eval("nth 2 rev3 1 2 3")
+      c.Assume(err, Equals, nil)
+      c.Expect(int(res[0].Int()), Equals, 1)
+    })
+    c.Specify("The index can be a subexpression.", func() {
+      res, err := context.Eval("nth - 2 1 makeTwo")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(int(res[0].Int()), Equals, 2)
+    })
+    c.Specify("nth produces a single argument for the function it is passed to.", func() {
+      res, err := context.Eval("+ nth 1 makeTwo 10")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(int(res[0].Int()), Equals, 12)
+    })
+    c.Specify("Out of range indices are an error.", func() {
+      _, err := context.Eval("nth 2 makeTwo")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, "Index 2 given to 'nth' is out of range for an expression that produced 2 value(s).")
+      _, err = context.Eval("nth -1 makeTwo")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("nth 0 makeZero")
+      c.Expect(err, Not(Equals), nil)
+    })
+    c.Specify("The index must be a single integer.", func() {
+      _, err := context.Eval("nth makeTwo x")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("nth [1] x")
+      c.Expect(err, Not(Equals), nil)
+    })
+    c.Specify("Missing terms are reported.", func() {
+      _, err := context.Eval("nth")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("nth 0")
+      c.Expect(err, Not(Equals), nil)
+    })
+  }
+  context := makeEngineContext(polish.Recursive)
+  c.Specify("nth parses into an index and an expression.", func() {
+    n, err := context.Parse("+ nth 1 makeTwo 10")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(n.Children), Equals, 2)
+    c.Expect(len(n.Children[0].Children), Equals, 2)
+    c.Expect(n.String(), Equals, "+ nth 1 makeTwo 10")
+    c.Expect(context.Check("+ nth 1 makeTwo 10"), Equals, nil)
+  })
+  c.Specify("nth is type checked.", func() {
+    context.AddFunc("pair", func() (int, string) { return 1, "a" })
+    typ, err := context.TypeCheck("nth 1 pair")
+    c.Assume(err, Equals, nil)
+    c.Expect(typ, Equals, reflect.TypeOf(""))
+    _, err = context.TypeCheck("nth 2 pair")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("nth cannot be used as a name.", func() {
+    c.Expect(context.AddFunc("nth", func() int { return 0 }), Not(Equals), nil)
+  })
+}
+
+func UnexpectedEndSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := makeEngineContext(engine)
+    expectError := func(expression, msg string) {
+      _, err := context.Eval(expression)
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, msg)
+    }
+    c.Specify("Empty expressions are an error rather than a panic.", func() {
+      expectError("", "Cannot evaluate an empty expression.")
+      expectError("   ", "Cannot evaluate an empty expression.")
+    })
+    c.Specify("A bare operator needs all of its arguments.", func() {
+      expectError("+", "Unexpected end of expression: '+' needs 2 more argument(s), its arguments so far produced 0 value(s).")
+    })
+    c.Specify("An operator with too few arguments says how many are missing.", func() {
+      expectError("+ 1", "Unexpected end of expression: '+' needs 1 more argument(s), its arguments so far produced 1 value(s).")
+      expectError("rev3 makeTwo", "Unexpected end of expression: 'rev3' needs 1 more argument(s), its arguments so far produced 2 value(s).")
+    })
+    c.Specify("A bare operator as an argument is reported for itself.", func() {
+      expectError("* 2 +", "Unexpected end of expression: '+' needs 2 more argument(s), its arguments so far produced 0 value(s).")
+    })
+  }
+}
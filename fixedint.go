@@ -0,0 +1,55 @@
+package polish
+
+import (
+  "math"
+)
+
+// Adds the same operators as AddIntMathContext, but using int64 for any
+// numerical values, and sets the default numeric type to Int64 so that integral
+// literals are parsed as int64.  Literals that do not fit in 64 bits are not
+// parsed as int64s, and so usually become float64s, unless SetStrictIntegers
+// makes them an Error.  Arithmetic wraps around on overflow, as it does in Go,
+// so results are the same on every architecture.
+//   Functions: + - * / // mod ^ pow abs < <= > >= ==
+func AddInt64MathContext(c *Context) {
+  c.SetDefaultNumeric(Int64)
+  c.addBuiltin("+", func(a, b int64) int64 { return a + b }, "Sum of two int64s.")
+  c.addBuiltin("-", func(a, b int64) int64 { return a - b }, "Difference of two int64s, a - b.")
+  c.addBuiltin("*", func(a, b int64) int64 { return a * b }, "Product of two int64s.")
+  c.addBuiltin("/", func(a, b int64) int64 { return a / b }, "Quotient of two int64s, a / b, truncated toward zero.")
+  c.addBuiltin("//", floorDiv[int64], "Quotient of two int64s, a / b, rounded toward negative infinity.")
+  c.addBuiltin("mod", euclideanMod[int64], "Euclidean modulus of two int64s, always in [0, |b|).")
+  c.addBuiltin("^", intPow[int64], "a raised to the power b, b must not be negative.")
+  c.addBuiltin("pow", func(a, b int64) float64 { return math.Pow(float64(a), float64(b)) }, "a raised to the power b as a float64, b may be negative.")
+  c.addBuiltin("abs", func(a int64) int64 { if a < 0 { return -a }; return a }, "Absolute value.")
+  c.addBuiltin("<", func(a, b int64) bool { return a < b }, "True if a < b.")
+  c.addBuiltin("<=", func(a, b int64) bool { return a <= b }, "True if a <= b.")
+  c.addBuiltin(">", func(a, b int64) bool { return a > b }, "True if a > b.")
+  c.addBuiltin(">=", func(a, b int64) bool { return a >= b }, "True if a >= b.")
+  c.addBuiltin("==", func(a, b int64) bool { return a == b }, "True if a == b.")
+}
+
+// Adds the same operators as AddIntMathContext, but using int32 for any
+// numerical values, and sets the default numeric type to Int32 so that integral
+// literals are parsed as int32.  Literals that do not fit in 32 bits are not
+// parsed as int32s, and so usually become float64s, unless SetStrictIntegers
+// makes them an Error.  Arithmetic wraps around on overflow, as it does in Go,
+// so results are the same on every architecture.
+//   Functions: + - * / // mod ^ pow abs < <= > >= ==
+func AddInt32MathContext(c *Context) {
+  c.SetDefaultNumeric(Int32)
+  c.addBuiltin("+", func(a, b int32) int32 { return a + b }, "Sum of two int32s.")
+  c.addBuiltin("-", func(a, b int32) int32 { return a - b }, "Difference of two int32s, a - b.")
+  c.addBuiltin("*", func(a, b int32) int32 { return a * b }, "Product of two int32s.")
+  c.addBuiltin("/", func(a, b int32) int32 { return a / b }, "Quotient of two int32s, a / b, truncated toward zero.")
+  c.addBuiltin("//", floorDiv[int32], "Quotient of two int32s, a / b, rounded toward negative infinity.")
+  c.addBuiltin("mod", euclideanMod[int32], "Euclidean modulus of two int32s, always in [0, |b|).")
+  c.addBuiltin("^", intPow[int32], "a raised to the power b, b must not be negative.")
+  c.addBuiltin("pow", func(a, b int32) float64 { return math.Pow(float64(a), float64(b)) }, "a raised to the power b as a float64, b may be negative.")
+  c.addBuiltin("abs", func(a int32) int32 { if a < 0 { return -a }; return a }, "Absolute value.")
+  c.addBuiltin("<", func(a, b int32) bool { return a < b }, "True if a < b.")
+  c.addBuiltin("<=", func(a, b int32) bool { return a <= b }, "True if a <= b.")
+  c.addBuiltin(">", func(a, b int32) bool { return a > b }, "True if a > b.")
+  c.addBuiltin(">=", func(a, b int32) bool { return a >= b }, "True if a >= b.")
+  c.addBuiltin("==", func(a, b int32) bool { return a == b }, "True if a == b.")
+}
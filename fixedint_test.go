@@ -0,0 +1,73 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "math"
+  "reflect"
+  "strings"
+)
+
+func Int64ContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddInt64MathContext(context)
+  c.Specify("Literals and results are int64.", func() {
+    res, err := context.Eval("+ 1 * 2 3")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Kind(), Equals, reflect.Int64)
+    c.Expect(res[0].Int(), Equals, int64(7))
+  })
+  c.Specify("Literals use the full 64 bits.", func() {
+    res, err := context.Eval("- 9223372036854775807 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(math.MaxInt64-1))
+  })
+  c.Specify("Overflow wraps around.", func() {
+    res, err := context.Eval("+ 9223372036854775807 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(math.MinInt64))
+  })
+  c.Specify("The other operators are available.", func() {
+    res, err := context.Eval("// -7 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(-4))
+    res, err = context.Eval("mod -7 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(1))
+    res, err = context.Eval("^ 2 62")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(1)<<62)
+    res, err = context.Eval("pow 2 -1")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 0.5)
+    _, err = context.Eval("/ 1 0")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func Int32ContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddInt32MathContext(context)
+  c.Specify("Literals and results are int32.", func() {
+    res, err := context.Eval("- 10 * 2 3")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Kind(), Equals, reflect.Int32)
+    c.Expect(res[0].Int(), Equals, int64(4))
+  })
+  c.Specify("Overflow wraps around at 32 bits.", func() {
+    res, err := context.Eval("+ 2147483647 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(math.MinInt32))
+    res, err = context.Eval("^ 2 31")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(math.MinInt32))
+  })
+  c.Specify("Literals that do not fit in 32 bits are errors.", func() {
+    _, err := context.Eval("+ 2147483648 1")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "see SetStrictIntegers"), Equals, true)
+  })
+}
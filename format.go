@@ -0,0 +1,123 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+  "strconv"
+  "strings"
+)
+
+// Sets the number of digits after the decimal point used when Format and
+// EvalToString render floating point values.  Trailing zeros are removed, so
+// with the default precision of 6, 1.5 is rendered as "1.5", 1/3 as
+// "0.333333", and 0.1 + 0.2 as "0.3".  A negative precision renders the
+// shortest decimal that parses back to exactly the same value, as
+// strconv.FormatFloat does with format 'g' and precision -1, which shows every
+// digit that distinguishes a value, such as rounding errors, and renders very
+// large or small values with an exponent.
+func (c *Context) SetFloatPrecision(prec int) {
+  c.float_prec = prec
+}
+
+// Sets a fmt format string, such as "%.4g", used when Format and EvalToString
+// render floating point values.  The format must contain exactly one verb that
+// accepts a float64.  This takes precedence over SetFloatPrecision, and passing
+// an empty string goes back to using the precision.  Computed values are never
+// affected, only how they are rendered.
+func (c *Context) SetFloatFormat(format string) error {
+  if format != "" {
+    s := fmt.Sprintf(format, 1.0)
+    if strings.Contains(s, "%!") {
+      return &Error{fmt.Sprintf("Invalid float format '%s', got '%s'.", format, s), nil, nil}
+    }
+  }
+  c.float_format = format
+  return nil
+}
+
+func (c *Context) formatFloat(f float64, bits int) string {
+  if c.float_format != "" {
+    return fmt.Sprintf(c.float_format, f)
+  }
+  if c.float_prec < 0 {
+    s := strconv.FormatFloat(f, 'g', -1, bits)
+    if s == "-0" {
+      s = "0"
+    }
+    return s
+  }
+  s := strconv.FormatFloat(f, 'f', c.float_prec, bits)
+  if strings.Contains(s, ".") {
+    s = strings.TrimRight(s, "0")
+    s = strings.TrimSuffix(s, ".")
+  }
+  if s == "-0" {
+    s = "0"
+  }
+  return s
+}
+
+// Sets a function that Format and EvalToString use to render values of type
+// t, including elements of lists, in place of the default rendering.  This
+// gives control over how custom types are displayed, which otherwise fall back
+// to fmt's %v.  The formatter is only used for values whose type is exactly t,
+// not for other types that implement t if it is an interface.  Passing a nil
+// format removes the formatter for t.
+func (c *Context) SetFormatter(t reflect.Type, format func(reflect.Value) string) {
+  if format == nil {
+    delete(c.formatters, t)
+    return
+  }
+  c.formatters[t] = format
+}
+
+func (c *Context) formatValue(v reflect.Value) string {
+  if !v.IsValid() {
+    return "<nil>"
+  }
+  if format, ok := c.formatters[v.Type()]; ok {
+    return format(v)
+  }
+  if t, ok := v.Interface().(Tuple); ok {
+    parts := make([]string, len(t))
+    for i := range t {
+      parts[i] = c.formatValue(t[i])
+    }
+    return "(" + strings.Join(parts, " ") + ")"
+  }
+  switch v.Kind() {
+  case reflect.Float32:
+    return c.formatFloat(v.Float(), 32)
+  case reflect.Float64:
+    return c.formatFloat(v.Float(), 64)
+  case reflect.Slice, reflect.Array:
+    parts := make([]string, v.Len())
+    for i := range parts {
+      parts[i] = c.formatValue(v.Index(i))
+    }
+    return "[" + strings.Join(parts, " ") + "]"
+  }
+  return fmt.Sprint(v.Interface())
+}
+
+// Renders values as text, separated by spaces.  Values of a type with a
+// formatter set by SetFormatter are rendered by it.  Otherwise floating point
+// values are rendered according to SetFloatFormat or SetFloatPrecision, lists
+// are rendered in the same bracketed form used to write them in expressions,
+// and everything else is rendered with fmt.
+func (c *Context) Format(vs ...reflect.Value) string {
+  parts := make([]string, len(vs))
+  for i, v := range vs {
+    parts[i] = c.formatValue(v)
+  }
+  return strings.Join(parts, " ")
+}
+
+// Evaluates an expression and renders its results with Format.
+func (c *Context) EvalToString(expression string) (string, error) {
+  vs, err := c.Eval(expression)
+  if err != nil {
+    return "", err
+  }
+  return c.Format(vs...), nil
+}
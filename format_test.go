@@ -0,0 +1,115 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "fmt"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+type point struct {
+  X, Y int
+}
+
+func FormatterSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  context.AddFunc("origin", func() point { return point{} })
+  context.AddFunc("pt", func(x, y float64) point { return point{int(x), int(y)} })
+  context.AddFunc("nothing", func() interface{} { return nil })
+  expectString := func(expression, expected string) {
+    s, err := context.EvalToString(expression)
+    c.Assume(err, Equals, nil)
+    c.Expect(s, Equals, expected)
+  }
+  c.Specify("Custom types fall back to fmt.", func() {
+    expectString("origin", "{0 0}")
+  })
+  c.Specify("Formatters render values of their type.", func() {
+    context.SetFormatter(reflect.TypeOf(point{}), func(v reflect.Value) string {
+      p := v.Interface().(point)
+      return fmt.Sprintf("(%d, %d)", p.X, p.Y)
+    })
+    expectString("origin", "(0, 0)")
+    expectString("[origin pt 1.0 2.0]", "[(0, 0) (1, 2)]")
+    context.SetFormatter(reflect.TypeOf(point{}), nil)
+    expectString("origin", "{0 0}")
+  })
+  c.Specify("Formatters take precedence over the float format.", func() {
+    context.SetFormatter(reflect.TypeOf(0.0), func(v reflect.Value) string { return fmt.Sprintf("%.1f!", v.Float()) })
+    expectString("+ 1.0 1.0", "2.0!")
+    context.SetFormatter(reflect.TypeOf(0.0), nil)
+    expectString("+ 1.0 1.0", "2")
+  })
+  c.Specify("nil values are rendered.", func() {
+    expectString("nothing", "<nil>")
+  })
+}
+
+func EvalToStringSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddVectorContext(context)
+  context.AddFunc("two", func() (float64, bool) { return 2, true })
+  context.AddFunc("name", func() string { return "polish" })
+  expectString := func(expression, expected string) {
+    s, err := context.EvalToString(expression)
+    c.Assume(err, Equals, nil)
+    c.Expect(s, Equals, expected)
+  }
+  c.Specify("Floats are rendered without rounding noise by default.", func() {
+    expectString("+ 1.0 0.5", "1.5")
+    expectString("* 2.0 2.0", "4")
+    expectString("0.3", "0.3")
+    expectString("+ 0.1 0.2", "0.3")
+    expectString("/ 1.0 3.0", "0.333333")
+    expectString("pi", "3.141593")
+    expectString("* -1.0 0.0", "0")
+    expectString("/ -1.0 0.0", "-Inf")
+  })
+  c.Specify("Float precision can be configured.", func() {
+    context.SetFloatPrecision(2)
+    expectString("/ 2.0 3.0", "0.67")
+    expectString("pi", "3.14")
+    context.SetFloatPrecision(6)
+    expectString("/ 1.0 3.0", "0.333333")
+  })
+  c.Specify("A negative precision renders the shortest decimal that round-trips.", func() {
+    context.SetFloatPrecision(-1)
+    expectString("0.3", "0.3")
+    expectString("/ 1.0 3.0", "0.3333333333333333")
+    expectString("pi", "3.141592653589793")
+    expectString("* 1e21 1.0", "1e+21")
+    expectString("/ 1.0 1e7", "1e-07")
+    expectString("* -1.0 0.0", "0")
+    context.SetFloatPrecision(6)
+  })
+  c.Specify("Float formats can be configured.", func() {
+    c.Assume(context.SetFloatFormat("%.4g"), Equals, nil)
+    expectString("pi", "3.142")
+    expectString("* 1000000.0 pi", "3.142e+06")
+    expectString("[1.0 2.5]", "[1 2.5]")
+    expectString("< 1.0 2.0", "true")
+    c.Assume(context.SetFloatFormat("%8.2f"), Equals, nil)
+    expectString("pi", "    3.14")
+    c.Assume(context.SetFloatFormat(""), Equals, nil)
+    expectString("pi", "3.141593")
+  })
+  c.Specify("Invalid float formats are rejected.", func() {
+    c.Expect(context.SetFloatFormat("%d"), Not(Equals), nil)
+    c.Expect(context.SetFloatFormat("%f %f"), Not(Equals), nil)
+    c.Expect(context.SetFloatFormat("no verb"), Not(Equals), nil)
+    expectString("pi", "3.141593")
+  })
+  c.Specify("Other kinds and multiple values are rendered.", func() {
+    expectString("< 1.0 2.0", "true")
+    expectString("name", "polish")
+    expectString("two", "2 true")
+    expectString("scale 0.5 [1.0 3.0]", "[0.5 1.5]")
+  })
+  c.Specify("Errors are returned.", func() {
+    _, err := context.EvalToString("+ 1.0")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
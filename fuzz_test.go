@@ -0,0 +1,71 @@
+package polish_test
+
+import (
+  "github.com/runningwild/polish"
+  "testing"
+)
+
+// Builds a Context from the built-in contexts selected by the bits of which.
+func makeFuzzContext(which uint8) *polish.Context {
+  context := polish.MakeContext()
+  if which&1 != 0 {
+    polish.AddIntMathContext(context)
+  } else {
+    polish.AddFloat64MathContext(context)
+  }
+  if which&2 != 0 {
+    polish.AddBooleanContext(context)
+  }
+  if which&4 != 0 {
+    polish.AddVectorContext(context)
+    polish.AddStatsContext(context)
+  }
+  if which&8 != 0 {
+    context.SetEngine(polish.Iterative)
+  }
+  if which&16 != 0 {
+    context.SetParseOrder(polish.Float, polish.Integer)
+  }
+  if which&32 != 0 {
+    context.SetStrictArity(true)
+  }
+  context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+  context.AddFunc("makeZero", func() {})
+  return context
+}
+
+func FuzzEval(f *testing.F) {
+  seeds := []string{
+    "",
+    " ",
+    "+",
+    "+ 1",
+    "+ 1 2",
+    "* 2.0 pi",
+    "- - makeTwo makeZero 3",
+    "[1.0 2.0",
+    "]",
+    "dot [1.0] [1.0 2.0]",
+    "^ 2 -1",
+    "^ 3 100000000",
+    "/ 1 0",
+    "'",
+    "'a",
+    "&& < 1.0 2.0 ! true",
+  }
+  for i, seed := range seeds {
+    f.Add(seed, uint8(i))
+  }
+  f.Fuzz(func(t *testing.T, expression string, which uint8) {
+    context := makeFuzzContext(which)
+    res, err := context.Eval(expression)
+    if err != nil {
+      if _, ok := err.(*polish.Error); !ok {
+        t.Fatalf("Eval(%q) returned a %T instead of a *polish.Error: %v", expression, err, err)
+      }
+      if res != nil {
+        t.Fatalf("Eval(%q) returned both values and an error", expression)
+      }
+    }
+  })
+}
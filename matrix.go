@@ -0,0 +1,123 @@
+package polish
+
+import (
+  "fmt"
+)
+
+// A Matrix is a square 2x2 or 3x3 matrix stored as a slice of rows.
+type Matrix [][]float64
+
+func makeMatrix(rows [][]float64) Matrix {
+  n := len(rows)
+  if n != 2 && n != 3 {
+    panic(fmt.Sprintf("A matrix must have 2 or 3 rows, not %d.", n))
+  }
+  m := make(Matrix, n)
+  for i, row := range rows {
+    if len(row) != n {
+      panic(fmt.Sprintf("Row %d of a %dx%d matrix has %d values.", i, n, n, len(row)))
+    }
+    m[i] = append([]float64(nil), row...)
+  }
+  return m
+}
+
+func (m Matrix) size() int {
+  return len(m)
+}
+
+func mMul(a, b Matrix) Matrix {
+  n := a.size()
+  if b.size() != n {
+    panic(fmt.Sprintf("Cannot multiply a %dx%d matrix by a %dx%d matrix.", n, n, b.size(), b.size()))
+  }
+  r := make(Matrix, n)
+  for i := range r {
+    r[i] = make([]float64, n)
+    for j := range r[i] {
+      for k := 0; k < n; k++ {
+        r[i][j] += a[i][k] * b[k][j]
+      }
+    }
+  }
+  return r
+}
+
+func mVecMul(a Matrix, v []float64) []float64 {
+  n := a.size()
+  if len(v) != n {
+    panic(fmt.Sprintf("Cannot multiply a %dx%d matrix by a vector of length %d.", n, n, len(v)))
+  }
+  r := make([]float64, n)
+  for i := range r {
+    for k := 0; k < n; k++ {
+      r[i] += a[i][k] * v[k]
+    }
+  }
+  return r
+}
+
+func mTranspose(a Matrix) Matrix {
+  n := a.size()
+  r := make(Matrix, n)
+  for i := range r {
+    r[i] = make([]float64, n)
+    for j := range r[i] {
+      r[i][j] = a[j][i]
+    }
+  }
+  return r
+}
+
+func mDet(a Matrix) float64 {
+  if a.size() == 2 {
+    return a[0][0]*a[1][1] - a[0][1]*a[1][0]
+  }
+  return a[0][0]*(a[1][1]*a[2][2]-a[1][2]*a[2][1]) -
+    a[0][1]*(a[1][0]*a[2][2]-a[1][2]*a[2][0]) +
+    a[0][2]*(a[1][0]*a[2][1]-a[1][1]*a[2][0])
+}
+
+func mInverse(a Matrix) Matrix {
+  det := mDet(a)
+  if det == 0 {
+    panic("Cannot invert a singular matrix.")
+  }
+  n := a.size()
+  r := make(Matrix, n)
+  for i := range r {
+    r[i] = make([]float64, n)
+  }
+  if n == 2 {
+    r[0][0], r[0][1] = a[1][1]/det, -a[0][1]/det
+    r[1][0], r[1][1] = -a[1][0]/det, a[0][0]/det
+    return r
+  }
+  // The inverse is the transpose of the cofactor matrix divided by the
+  // determinant.
+  for i := 0; i < 3; i++ {
+    for j := 0; j < 3; j++ {
+      i1, i2 := (i+1)%3, (i+2)%3
+      j1, j2 := (j+1)%3, (j+2)%3
+      r[j][i] = (a[i1][j1]*a[i2][j2] - a[i1][j2]*a[i2][j1]) / det
+    }
+  }
+  return r
+}
+
+// Adds operators on 2x2 and 3x3 matrices to the Context.  Inside an
+// expression a Matrix is built from a list of rows with the matrix function,
+// for example "det matrix [[1.0 2.0] [3.0 4.0]]".  Inverting a singular matrix
+// is an error.
+//   Functions: matrix    (makes a Matrix from a [][]float64)
+//              m*        (matrix product)
+//              mv*       (product of a matrix and a []float64 vector)
+//              transpose det inverse
+func AddMatrixContext(c *Context) {
+  c.addBuiltin("matrix", makeMatrix, "Makes a 2x2 or 3x3 Matrix from a list of rows.")
+  c.addBuiltin("m*", mMul, "Product of two matrices.")
+  c.addBuiltin("mv*", mVecMul, "Product of a matrix and a vector.")
+  c.addBuiltin("transpose", mTranspose, "Transpose of a matrix.")
+  c.addBuiltin("det", mDet, "Determinant of a matrix.")
+  c.addBuiltin("inverse", mInverse, "Inverse of a non-singular matrix.")
+}
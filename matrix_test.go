@@ -0,0 +1,49 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func MatrixContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddMatrixContext(context)
+  context.SetDefaultNumeric(polish.Float)
+  expectMatrix := func(expression string, expected polish.Matrix) {
+    res, err := context.Eval(expression)
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(reflect.DeepEqual(res[0].Interface(), expected), Equals, true)
+  }
+  c.Specify("Matrices can be built, multiplied, and transposed.", func() {
+    expectMatrix("matrix [[1 2] [3 4]]", polish.Matrix{{1, 2}, {3, 4}})
+    expectMatrix("m* matrix [[1 2] [3 4]] matrix [[0 1] [1 0]]", polish.Matrix{{2, 1}, {4, 3}})
+    expectMatrix("transpose matrix [[1 2 3] [4 5 6] [7 8 9]]", polish.Matrix{{1, 4, 7}, {2, 5, 8}, {3, 6, 9}})
+    res, err := context.Eval("mv* matrix [[0 -1] [1 0]] [1 0]")
+    c.Assume(err, Equals, nil)
+    c.Expect(reflect.DeepEqual(res[0].Interface(), []float64{0, 1}), Equals, true)
+  })
+  c.Specify("Determinants and inverses work.", func() {
+    res, err := context.Eval("det matrix [[1 2] [3 4]]")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, -2.0)
+    res, err = context.Eval("det matrix [[2 0 0] [0 3 0] [0 0 4]]")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 24.0)
+    expectMatrix("inverse matrix [[4 7] [2 6]]", polish.Matrix{{0.6, -0.7}, {-0.2, 0.4}})
+    expectMatrix("inverse matrix [[2 0 0] [0 4 0] [0 0 8]]", polish.Matrix{{0.5, 0, 0}, {0, 0.25, 0}, {0, 0, 0.125}})
+    expectMatrix("m* matrix [[1 2 3] [0 1 4] [5 6 0]] inverse matrix [[1 2 3] [0 1 4] [5 6 0]]", polish.Matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}})
+  })
+  c.Specify("Invalid matrices are errors.", func() {
+    _, err := context.Eval("inverse matrix [[1 2] [2 4]]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("matrix [[1 2] [3]]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("matrix [[1]]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("m* matrix [[1 2] [3 4]] matrix [[1 0 0] [0 1 0] [0 0 1]]")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
@@ -0,0 +1,110 @@
+package polish
+
+import (
+  "fmt"
+  "math"
+  "reflect"
+)
+
+// Adds arithmetic and comparison operators that accept both ints and
+// float64s, for expressions that mix the two, which AddIntMathContext and
+// AddFloat64MathContext cannot be used together to do since they define the
+// same operators.
+//   Functions: + - * / ^ abs min max < <= > >= ==
+// If both operands are ints the operation is done on ints and produces an int,
+// exactly as in AddIntMathContext, so / 7 2 is 3 and ^ only accepts
+// non-negative exponents.  If either operand is a float64 the other is
+// converted to a float64 and the operation produces a float64, exactly as in
+// AddFloat64MathContext, so / 7 2.0 is 3.5.  Any other type of operand is an
+// Error.  This is the recommended setup when users write expressions by hand:
+//   c := polish.MakeContext()
+//   polish.AddNumericContext(c)
+//   polish.AddBooleanContext(c)
+// Since the results of these functions depend on their operands, TypeCheck
+// cannot know their types.
+func AddNumericContext(c *Context) {
+  c.addBuiltin("+", numericOp("+", func(a, b int) interface{} { return a + b }, func(a, b float64) interface{} { return a + b }), "Sum of two numbers.")
+  c.addBuiltin("-", numericOp("-", func(a, b int) interface{} { return a - b }, func(a, b float64) interface{} { return a - b }), "Difference of two numbers, a - b.")
+  c.addBuiltin("*", numericOp("*", func(a, b int) interface{} { return a * b }, func(a, b float64) interface{} { return a * b }), "Product of two numbers.")
+  c.addBuiltin("/", numericOp("/", iDiv, func(a, b float64) interface{} { return a / b }), "Quotient of two numbers, a / b, truncated toward zero if both are ints.")
+  c.addBuiltin("^", numericOp("^", func(a, b int) interface{} { return intPow(a, b) }, func(a, b float64) interface{} { return math.Pow(a, b) }), "a raised to the power b, b must not be negative if both are ints.")
+  c.addBuiltin("min", numericOp("min", func(a, b int) interface{} { if a < b { return a }; return b }, func(a, b float64) interface{} { return math.Min(a, b) }), "The smaller of two numbers.")
+  c.addBuiltin("max", numericOp("max", func(a, b int) interface{} { if a > b { return a }; return b }, func(a, b float64) interface{} { return math.Max(a, b) }), "The larger of two numbers.")
+  c.addBuiltin("abs", func(a interface{}) interface{} {
+    i, f, is_int := toNumber("abs", a)
+    if !is_int {
+      return math.Abs(f)
+    }
+    if i < 0 {
+      return -i
+    }
+    return i
+  }, "Absolute value.")
+  c.addBuiltin("<", numericComparison(c, "<", func(a, b int) bool { return a < b }, func(a, b float64) bool { return a < b }), "True if a < b.")
+  c.addBuiltin("<=", numericComparison(c, "<=", func(a, b int) bool { return a <= b }, func(a, b float64) bool { return a <= b }), "True if a <= b.")
+  c.addBuiltin(">", numericComparison(c, ">", func(a, b int) bool { return a > b }, func(a, b float64) bool { return a > b }), "True if a > b.")
+  c.addBuiltin(">=", numericComparison(c, ">=", func(a, b int) bool { return a >= b }, func(a, b float64) bool { return a >= b }), "True if a >= b.")
+  c.addBuiltin("==", numericComparison(c, "==", func(a, b int) bool { return a == b }, func(a, b float64) bool { return a == b || math.Abs(a-b) <= c.float_epsilon }), "True if a == b, floats are compared within the Context's float epsilon.")
+}
+
+// Division truncating toward zero, with a clearer error than Go's for a zero
+// divisor.
+func iDiv(a, b int) interface{} {
+  if b == 0 {
+    panic("Cannot divide an int by zero.")
+  }
+  return a / b
+}
+
+// Returns v as an int if it is one, and as a float64 either way.
+func toNumber(op string, v interface{}) (i int, f float64, is_int bool) {
+  switch n := v.(type) {
+  case int:
+    return n, float64(n), true
+  case float64:
+    return 0, n, false
+  }
+  panic(fmt.Sprintf("'%s' needs an int or a float64, not a %T", op, v))
+}
+
+// Returns a function that applies ints if both of its operands are ints and
+// floats otherwise.
+func numericOp(op string, ints func(a, b int) interface{}, floats func(a, b float64) interface{}) func(a, b interface{}) interface{} {
+  return func(a, b interface{}) interface{} {
+    ai, af, a_int := toNumber(op, a)
+    bi, bf, b_int := toNumber(op, b)
+    if a_int && b_int {
+      return ints(ai, bi)
+    }
+    return floats(af, bf)
+  }
+}
+
+// Like numericOp, but for comparisons, where floats follow the Context's
+// NaNMode.
+func numericComparison(c *Context, op string, ints func(a, b int) bool, floats func(a, b float64) bool) func(a, b interface{}) bool {
+  floats = c.floatComparison(op, floats)
+  return func(a, b interface{}) bool {
+    ai, af, a_int := toNumber(op, a)
+    bi, bf, b_int := toNumber(op, b)
+    if a_int && b_int {
+      return ints(ai, bi)
+    }
+    return floats(af, bf)
+  }
+}
+
+// Returns a hint to add to an error about passing a value of type arg to a
+// parameter of type param, if the mistake is likely to be mixing ints and
+// float64s.
+func numericHint(arg, param reflect.Type) string {
+  if kindClass(arg.Kind()) == reflect.Int && kindClass(param.Kind()) == reflect.Float64 {
+    return "  Literals such as 2 are ints and 2.0 is a float64, see SetDefaultNumeric, or use AddNumericContext to mix them."
+  }
+  if kindClass(arg.Kind()) == reflect.Float64 && kindClass(param.Kind()) == reflect.Int {
+    // An integer literal that is out of range for its type is also parsed
+    // as a float64, in which case mixing types is not the mistake.
+    return "  Literals such as 2 are ints and 2.0 is a float64, see SetDefaultNumeric, or use AddNumericContext to mix them.  Integer literals that are out of range are float64s too, see SetStrictIntegers."
+  }
+  return ""
+}
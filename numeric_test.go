@@ -0,0 +1,78 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+  "strings"
+)
+
+func NumericContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddNumericContext(context)
+  polish.AddBooleanContext(context)
+  eval := func(expression string) interface{} {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    return res[0].Interface()
+  }
+  c.Specify("Operations on ints produce ints.", func() {
+    c.Expect(eval("+ 1 2"), Equals, 3)
+    c.Expect(eval("/ 7 2"), Equals, 3)
+    c.Expect(eval("^ 2 10"), Equals, 1024)
+    c.Expect(eval("abs -4"), Equals, 4)
+    c.Expect(eval("min 3 -1"), Equals, -1)
+  })
+  c.Specify("Mixing in a float64 produces a float64.", func() {
+    c.Expect(eval("+ 1 2.5"), Equals, 3.5)
+    c.Expect(eval("/ 7 2.0"), Equals, 3.5)
+    c.Expect(eval("* 2.0 + 1 2"), Equals, 6.0)
+    c.Expect(eval("max 1 0.5"), Equals, 1.0)
+    c.Expect(eval("abs -0.5"), Equals, 0.5)
+  })
+  c.Specify("Comparisons accept either.", func() {
+    c.Expect(eval("< 1 1.5"), Equals, true)
+    c.Expect(eval("&& == 2 2.0 >= 3 3"), Equals, true)
+  })
+  c.Specify("Results can be passed to functions that take their real type.", func() {
+    context.AddFunc("half", func(a float64) float64 { return a / 2 })
+    c.Expect(eval("half + 1 2.0"), Equals, 1.5)
+    _, err := context.Eval("half + 1 2")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Other operands and zero int divisors are errors.", func() {
+    _, err := context.Eval("+ 1 true")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("/ 1 0")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("^ 2 -1")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("TypeCheck cannot know the types of the results.", func() {
+    t, err := context.TypeCheck("+ 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(t, Equals, reflect.Type(nil))
+  })
+}
+
+func NumericHintSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  c.Specify("Passing an int to a float64 parameter explains why.", func() {
+    _, err := context.Eval("+ 1.5 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "2.0 is a float64"), Equals, true)
+    c.Expect(strings.Contains(err.Error(), "AddNumericContext"), Equals, true)
+    _, err = context.TypeCheck("+ 1.5 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "AddNumericContext"), Equals, true)
+  })
+  c.Specify("Other mismatches do not mention it.", func() {
+    context.AddFunc("name", func() string { return "x" })
+    _, err := context.Eval("+ 1.5 name")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "AddNumericContext"), Equals, false)
+  })
+}
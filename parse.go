@@ -0,0 +1,575 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+  "strings"
+)
+
+// A Node is one term of a parsed expression along with the subexpressions
+// that make up its arguments.  A list is a Node whose Term is "[" and whose
+// Children are its elements.  A bind is a Node with three Children: a Node
+// whose Term is "(" and whose Children are the names, the expression being
+// bound, and the body.  A fold or map is a Node whose first child is the
+// function being applied, which has no children of its own.  An nth is a
+// Node with two Children, the index and the expression it selects from.  A
+// tuple or untuple is a Node whose first child is the number of values,
+// followed by the subexpressions that supply them.  An apply is a Node whose
+// first child produces the func and whose other children are its arguments.
+type Node struct {
+  Term     string
+  Children []*Node
+
+  // Comments that came just before Term, including their leading #, see
+  // SetComments.  Comments just before a ']', or among the names of a bind,
+  // are not kept.
+  Comments []string
+
+  // Only used for the root of a parsed expression, the comments that came
+  // after every other term.
+  Trailing []string
+
+  // Set by Parse for calls to functions and for the raw terms consumed by a
+  // token function, which Substitute leaves alone.
+  fixed bool
+}
+
+// Parses an expression into a tree of Nodes without evaluating it.  The shape
+// of the tree is decided the same way Eval decides it, using the number of
+// inputs and outputs of each function, so a function's children are the
+// subexpressions that supply its arguments.  As with Eval, only the first
+// complete subexpression is parsed and any remaining terms are ignored.
+func (c *Context) Parse(expression string) (*Node, error) {
+  terms, comments, err := c.tokenizeComments(expression)
+  if err != nil {
+    return nil, err
+  }
+  p := makeParser(c, terms)
+  p.comments = comments
+  n, _, err := p.parse("", 0)
+  if err != nil {
+    return nil, err
+  }
+  if len(p.terms) == 0 {
+    n.Trailing = comments[len(terms)]
+  }
+  return n, nil
+}
+
+// Checks that expression is well-formed without evaluating it, using the
+// number of inputs and outputs of each function.  This is stricter than Eval:
+// every term must be used, and the arguments of each function must produce
+// exactly as many values as it takes, as with SetStrictArity, so that values
+// never pass from a function's arguments to an outer function.  The Error
+// gives the position of the offending term, counting from 1.  Terms that are
+// not functions are assumed to be single values.
+func (c *Context) Check(expression string) error {
+  terms, err := c.tokenize(expression)
+  if err != nil {
+    return err
+  }
+  p := makeParser(c, terms)
+  p.strict = true
+  if _, _, err := p.parse("", 0); err != nil {
+    return err
+  }
+  if len(p.terms) > 0 {
+    return &Error{fmt.Sprintf("Term %d ('%s') is not used by the expression.", p.position(), p.terms[0]), nil, nil}
+  }
+  return nil
+}
+
+// Checks each of exprs with Check, without evaluating anything, and returns
+// their errors in the same order, nil for each expression that is valid.  This
+// is meant for checking a batch of expressions up front, such as the formulas
+// in a config file, where every broken one should be reported rather than
+// only the first.
+func (c *Context) Validate(exprs []string) []error {
+  errs := make([]error, len(exprs))
+  for i, expression := range exprs {
+    errs[i] = c.Check(expression)
+  }
+  return errs
+}
+
+type parser struct {
+  c     *Context
+  terms []string
+
+  // Number of terms in the whole expression.
+  count int
+
+  // If set, surplus values are an error regardless of SetStrictArity.
+  strict bool
+
+  // Set when parsing fails because the expression ended too soon.
+  ended bool
+
+  // Comments keyed by the index of the term that follows them.
+  comments map[int][]string
+}
+
+func makeParser(c *Context, terms []string) *parser {
+  return &parser{c: c, terms: terms, count: len(terms)}
+}
+
+// Returns an error for an expression that ended before it was complete.
+func (p *parser) unexpectedEnd(msg string) error {
+  p.ended = true
+  return &Error{msg, nil, nil}
+}
+
+// Returns the position of the next term, counting from 1.
+func (p *parser) position() int {
+  return p.count - len(p.terms) + 1
+}
+
+// Parses the next complete term and returns it along with the number of values
+// it will produce when evaluated.
+func (p *parser) parse(parent string, arg int) (*Node, int, error) {
+  if len(p.terms) == 0 {
+    if parent == "" {
+      return nil, 0, p.unexpectedEnd("Cannot parse an empty expression.")
+    }
+    return nil, 0, p.unexpectedEnd(fmt.Sprintf("Unexpected end of expression: '%s' needs more arguments.", parent))
+  }
+  pos := p.position()
+  n := &Node{Term: p.terms[0], Comments: p.comments[pos-1]}
+  p.terms = p.terms[1:]
+  switch n.Term {
+  case "[":
+    for len(p.terms) == 0 || p.terms[0] != "]" {
+      if len(p.terms) == 0 {
+        return nil, 0, p.unexpectedEnd(fmt.Sprintf("Found '[' at term %d without a matching ']'.", pos))
+      }
+      child, _, err := p.parse("[", len(n.Children))
+      if err != nil {
+        return nil, 0, err
+      }
+      n.Children = append(n.Children, child)
+    }
+    p.terms = p.terms[1:]
+    return n, 1, nil
+
+  case "]":
+    return nil, 0, &Error{fmt.Sprintf("Found ']' at term %d without a matching '['.", pos), nil, nil}
+
+  case "bind":
+    names, rest, err := p.c.bindNames(p.terms)
+    if err != nil {
+      if len(p.terms) == 0 || strings.HasPrefix(p.terms[0], "(") && !closesNames(p.terms) {
+        p.ended = true
+      }
+      return nil, 0, err
+    }
+    p.terms = rest
+    group := &Node{Term: "("}
+    for _, name := range names {
+      group.Children = append(group.Children, &Node{Term: name})
+    }
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd("Unexpected end of expression: 'bind' needs an expression to bind and a body.")
+    }
+    value, outputs, err := p.parse(n.Term, 0)
+    if err != nil {
+      return nil, 0, err
+    }
+    if outputs != len(names) {
+      return nil, 0, &Error{fmt.Sprintf("bind was given %d name(s) but its expression produced %d value(s).", len(names), outputs), nil, nil}
+    }
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd("Unexpected end of expression: 'bind' needs a body.")
+    }
+    body, outputs, err := p.parse(n.Term, 1)
+    if err != nil {
+      return nil, 0, err
+    }
+    n.Children = []*Node{group, value, body}
+    return n, outputs, nil
+
+  case "nth":
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd("Unexpected end of expression: 'nth' needs an index and an expression.")
+    }
+    index, outputs, err := p.parse(n.Term, 0)
+    if err != nil {
+      return nil, 0, err
+    }
+    if outputs != 1 {
+      return nil, 0, &Error{fmt.Sprintf("The index given to 'nth' at term %d must be a single value, but it produced %d value(s).", pos, outputs), nil, nil}
+    }
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd("Unexpected end of expression: 'nth' needs an expression.")
+    }
+    value, _, err := p.parse(n.Term, 1)
+    if err != nil {
+      return nil, 0, err
+    }
+    n.Children = []*Node{index, value}
+    return n, 1, nil
+
+  case "tuple", "untuple":
+    count, err := tupleCount(n.Term, p.terms)
+    if err != nil {
+      if len(p.terms) == 0 {
+        p.ended = true
+      }
+      return nil, 0, err
+    }
+    n.Children = []*Node{{Term: p.terms[0]}}
+    p.terms = p.terms[1:]
+    num := 0
+    for (n.Term == "tuple" && num < count) || (n.Term == "untuple" && len(n.Children) < 2) {
+      if len(p.terms) == 0 {
+        return nil, 0, p.unexpectedEnd(fmt.Sprintf("Unexpected end of expression: '%s %d' at term %d needs more values.", n.Term, count, pos))
+      }
+      child, outputs, err := p.parse(n.Term, len(n.Children)-1)
+      if err != nil {
+        return nil, 0, err
+      }
+      n.Children = append(n.Children, child)
+      num += outputs
+    }
+    if n.Term == "untuple" {
+      if num != 1 {
+        return nil, 0, &Error{fmt.Sprintf("'untuple %d' at term %d needs a single Tuple, but its expression produces %d values.", count, pos, num), nil, nil}
+      }
+      return n, count, nil
+    }
+    if num != count {
+      return nil, 0, &Error{fmt.Sprintf("'tuple %d' at term %d was given %d values.", count, pos, num), nil, nil}
+    }
+    return n, 1, nil
+
+  case "apply":
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd("Unexpected end of expression: 'apply' needs a func and its arguments.")
+    }
+    fn, outputs, err := p.parse(n.Term, 0)
+    if err != nil {
+      return nil, 0, err
+    }
+    if outputs != 1 {
+      return nil, 0, &Error{fmt.Sprintf("'apply' at term %d needs a single func, but its first expression produces %d values.", pos, outputs), nil, nil}
+    }
+    tc := typeChecker{c: p.c, bound: make(map[string]reflect.Type)}
+    types, err := tc.check(fn)
+    if err != nil {
+      return nil, 0, err
+    }
+    if types[0] == nil {
+      return nil, 0, &Error{fmt.Sprintf("The type of the func given to 'apply' at term %d cannot be known without evaluating it.", pos), nil, nil}
+    }
+    if err := checkApplied(types[0]); err != nil {
+      return nil, 0, err
+    }
+    n.Children = []*Node{fn}
+    args := types[0].NumIn()
+    num := 0
+    for num < args {
+      if len(p.terms) == 0 {
+        return nil, 0, p.unexpectedEnd(fmt.Sprintf("Unexpected end of expression: 'apply' at term %d needs %d more argument(s), its arguments so far produced %d value(s).", pos, args-num, num))
+      }
+      child, outputs, err := p.parse(n.Term, num)
+      if err != nil {
+        return nil, 0, err
+      }
+      n.Children = append(n.Children, child)
+      num += outputs
+    }
+    if num > args && (p.c.strict_arity || p.strict) {
+      return nil, 0, &Error{fmt.Sprintf("'apply' at term %d takes %d argument(s) but was given %d values.", pos, args, num), nil, nil}
+    }
+    return n, types[0].NumOut() + num - args, nil
+
+  case "fold", "map":
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd(fmt.Sprintf("Unexpected end of expression: '%s' needs a function.", n.Term))
+    }
+    ref := &Node{Term: p.terms[0]}
+    p.terms = p.terms[1:]
+    // fold takes an initial value and a list, map takes just a list, and
+    // their functions take one more argument than map does.
+    args := 2
+    if n.Term == "map" {
+      args = 1
+    }
+    if _, err := p.c.referencedFunc(n.Term, ref.Term, args); err != nil {
+      return nil, 0, err
+    }
+    n.Children = []*Node{ref}
+    num := 0
+    for len(n.Children) <= args {
+      if len(p.terms) == 0 {
+        return nil, 0, p.unexpectedEnd(fmt.Sprintf("Unexpected end of expression: '%s' at term %d needs %d more argument(s).", n.Term, pos, args+1-len(n.Children)))
+      }
+      child, outputs, err := p.parse(n.Term, len(n.Children)-1)
+      if err != nil {
+        return nil, 0, err
+      }
+      n.Children = append(n.Children, child)
+      num += outputs
+    }
+    if num != args {
+      return nil, 0, &Error{fmt.Sprintf("'%s' at term %d takes %d argument(s) but was given %d value(s).", n.Term, pos, args, num), nil, nil}
+    }
+    return n, 1, nil
+  }
+  f, ok := p.c.lookupFunc(n.Term)
+  if !ok {
+    if p.c.disabled[n.Term] && !p.c.hasValue(n.Term) {
+      return nil, 0, p.c.disabledError(n.Term)
+    }
+    return n, 1, nil
+  }
+  n.fixed = true
+  if f.token {
+    vs, consumed, err := callTokenFunc(n.Term, f, p.terms)
+    if err != nil {
+      return nil, 0, err
+    }
+    for _, term := range p.terms[:consumed] {
+      n.Children = append(n.Children, &Node{Term: term, fixed: true})
+    }
+    p.terms = p.terms[consumed:]
+    return n, len(vs), nil
+  }
+  num := 0
+  for num < f.num {
+    if len(p.terms) == 0 {
+      return nil, 0, p.unexpectedEnd(fmt.Sprintf("Unexpected end of expression: '%s' at term %d needs %d more argument(s), its arguments so far produced %d value(s).", n.Term, pos, f.num-num, num))
+    }
+    child, outputs, err := p.parse(n.Term, num)
+    if err != nil {
+      return nil, 0, err
+    }
+    n.Children = append(n.Children, child)
+    num += outputs
+  }
+  if num > f.num && (p.c.strict_arity || p.strict) {
+    return nil, 0, &Error{fmt.Sprintf("Function '%s' at term %d takes %d argument(s) but was given %d values.", n.Term, pos, f.num, num), nil, nil}
+  }
+  return n, f.f.Type().NumOut() + num - f.num, nil
+}
+
+// Returns whether any of terms would close the names of a bind.
+func closesNames(terms []string) bool {
+  for _, term := range terms {
+    if strings.HasSuffix(term, ")") {
+      return true
+    }
+  }
+  return false
+}
+
+// Reports whether expression is complete, meaning that it has enough terms
+// for every function to be given all of its arguments, without evaluating
+// it.  An expression that could still be completed by adding more terms, such
+// as "+ 1" or "[ 1 2", is incomplete and returns false with no error, while
+// one that is wrong no matter what follows, such as "]", returns an error.
+// An empty expression is incomplete.  As with Eval, terms after the first
+// complete expression are ignored.
+func (c *Context) IsComplete(expression string) (bool, error) {
+  terms, err := c.tokenize(expression)
+  if err != nil {
+    return false, err
+  }
+  p := makeParser(c, terms)
+  _, _, err = p.parse("", 0)
+  if err != nil {
+    if p.ended {
+      return false, nil
+    }
+    return false, err
+  }
+  return true, nil
+}
+
+// Calls visit on n and every Node below it in pre-order, so a Node is always
+// visited before its children, which are visited left to right in the order
+// their terms appear in the expression.  If visit returns false then the
+// children of that Node are skipped, but the walk continues with its
+// siblings.  Since the children are read after visit returns, visit may
+// replace them, and the walk then goes through the new ones.  For a
+// post-order walk, where every child is seen before its parent, collect the
+// Nodes in a slice and go through it backwards, which visits the children
+// right to left.  The names of a bind and the function of a fold or map are
+// Nodes like any other and are visited too.
+func (n *Node) Walk(visit func(*Node) bool) {
+  if !visit(n) {
+    return
+  }
+  for _, child := range n.Children {
+    child.Walk(visit)
+  }
+}
+
+// Returns a copy of n in which every term that refers to the value name is
+// replaced by a copy of replacement, which can be a single term such as a
+// constant or a whole subexpression, and leaves n unchanged.  The result can
+// be evaluated with EvalNode or turned back into an expression with String.
+// Calls to functions are left alone, even those without arguments, as are the
+// terms consumed by a token function, the names of a bind, the function of a
+// fold or map, and the number of values of a tuple or untuple.  Nodes that
+// were not made by Parse are treated as values whenever they have no children.  A bind of name shadows it for
+// the rest of the expression, as it does during evaluation, so nothing after
+// the expression being bound is replaced.  The replacement should produce a
+// single value, otherwise the result will not be evaluated the same way it
+// was parsed.
+func (n *Node) Substitute(name string, replacement *Node) *Node {
+  shadowed := false
+  return n.substitute(name, replacement, &shadowed)
+}
+
+func (n *Node) substitute(name string, replacement *Node, shadowed *bool) *Node {
+  if !*shadowed && n.Term == name && len(n.Children) == 0 && !n.fixed {
+    r := replacement.copy()
+    r.Comments = append(append([]string(nil), n.Comments...), r.Comments...)
+    r.Trailing = n.Trailing
+    return r
+  }
+  m := &Node{
+    Term:     n.Term,
+    Comments: append([]string(nil), n.Comments...),
+    Trailing: append([]string(nil), n.Trailing...),
+    fixed:    n.fixed,
+  }
+  for i, child := range n.Children {
+    switch n.Term {
+    case "bind", "fold", "map", "tuple", "untuple":
+      if i == 0 {
+        m.Children = append(m.Children, child.copy())
+        continue
+      }
+    }
+    m.Children = append(m.Children, child.substitute(name, replacement, shadowed))
+    if n.Term == "bind" && i == 1 {
+      for _, bound := range n.Children[0].Children {
+        if bound.Term == name {
+          *shadowed = true
+        }
+      }
+    }
+  }
+  return m
+}
+
+// Returns a copy of n that shares nothing with it.
+func (n *Node) copy() *Node {
+  m := &Node{
+    Term:     n.Term,
+    Comments: append([]string(nil), n.Comments...),
+    Trailing: append([]string(nil), n.Trailing...),
+    fixed:    n.fixed,
+  }
+  for _, child := range n.Children {
+    m.Children = append(m.Children, child.copy())
+  }
+  return m
+}
+
+// Appends the terms that make up n to terms.
+func (n *Node) appendTerms(terms []string) []string {
+  terms = append(terms, n.Term)
+  for i, child := range n.Children {
+    terms = child.appendTerms(terms)
+    if n.Term == "bind" && i == 0 {
+      terms = append(terms, ")")
+    }
+  }
+  if n.Term == "[" {
+    terms = append(terms, "]")
+  }
+  return terms
+}
+
+// Returns the expression that n was parsed from, with its terms separated by
+// single spaces.  Comments are each followed by a newline, except for a
+// trailing comment at the very end.
+func (n *Node) String() string {
+  var b strings.Builder
+  n.write(&b)
+  for _, comment := range n.Trailing {
+    writeSeparated(&b, comment)
+    b.WriteString("\n")
+  }
+  return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Writes the terms and comments that make up n.
+func (n *Node) write(b *strings.Builder) {
+  for _, comment := range n.Comments {
+    writeSeparated(b, comment)
+    b.WriteString("\n")
+  }
+  writeSeparated(b, n.Term)
+  for i, child := range n.Children {
+    child.write(b)
+    if n.Term == "bind" && i == 0 {
+      writeSeparated(b, ")")
+    }
+  }
+  if n.Term == "[" {
+    writeSeparated(b, "]")
+  }
+}
+
+// Writes s, preceded by a space unless it starts a line.
+func writeSeparated(b *strings.Builder, s string) {
+  if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+    b.WriteString(" ")
+  }
+  b.WriteString(s)
+}
+
+// Evaluates the subexpression rooted at n exactly as Eval would evaluate the
+// expression it came from.  The Node does not need to be the root of a parsed
+// expression, and it can be one that was built or modified by hand.
+func (c *Context) EvalNode(n *Node) ([]reflect.Value, error) {
+  terms := n.appendTerms(nil)
+  return c.evaluate(strings.Join(terms, " "), &evaluation{c: c, terms: terms})
+}
+
+// Reports whether two expressions have the same structure once parsed, so
+// that differences in spacing, comments, and how literals are written, such as
+// 1.0 and 1.00, do not matter.  Functions and values must have the same names
+// and literals must parse to equal values of the same type.  Nothing is
+// evaluated and no algebra is done, so + 1 2 and + 2 1 are different.  As with
+// Parse, terms after the first complete expression are ignored, and an error
+// is returned if either expression cannot be parsed.
+func (c *Context) Equal(a, b string) (bool, error) {
+  na, err := c.Parse(a)
+  if err != nil {
+    return false, err
+  }
+  nb, err := c.Parse(b)
+  if err != nil {
+    return false, err
+  }
+  return c.equalNodes(na, nb), nil
+}
+
+func (c *Context) equalNodes(a, b *Node) bool {
+  if len(a.Children) != len(b.Children) || c.normalize(a.Term) != c.normalize(b.Term) {
+    return false
+  }
+  for i := range a.Children {
+    if !c.equalNodes(a.Children[i], b.Children[i]) {
+      return false
+    }
+  }
+  return true
+}
+
+// Returns a form of term that is the same for every way of writing the same
+// literal.  Functions, values and special terms are left as they are.
+func (c *Context) normalize(term string) string {
+  if _, ok := c.lookupFunc(term); ok || c.hasValue(term) || special_forms[term] {
+    return term
+  }
+  val, _, err := c.parseLiteral(term)
+  if err != nil || !val.IsValid() {
+    return term
+  }
+  return fmt.Sprintf("%v %v", val.Type(), val.Interface())
+}
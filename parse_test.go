@@ -0,0 +1,350 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+  "strings"
+)
+
+func ParseSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("rev3", func(a, b, c int) (int, int, int) { return c, b, a })
+  c.Specify("Expressions parse into trees.", func() {
+    n, err := context.Parse("+ 1 * 2 x")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.Term, Equals, "+")
+    c.Assume(len(n.Children), Equals, 2)
+    c.Expect(n.Children[0].Term, Equals, "1")
+    c.Expect(len(n.Children[0].Children), Equals, 0)
+    c.Expect(n.Children[1].Term, Equals, "*")
+    c.Assume(len(n.Children[1].Children), Equals, 2)
+    c.Expect(n.Children[1].Children[1].Term, Equals, "x")
+  })
+  c.Specify("Multiple values count toward a parent's arguments.", func() {
+    n, err := context.Parse("- - rev3 1 2 3")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(n.Children), Equals, 1)
+    c.Assume(len(n.Children[0].Children), Equals, 1)
+    c.Expect(n.Children[0].Children[0].Term, Equals, "rev3")
+  })
+  c.Specify("Lists parse into a node with their elements as children.", func() {
+    n, err := context.Parse("[1 + 2 3 4]")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.Term, Equals, "[")
+    c.Expect(len(n.Children), Equals, 3)
+  })
+  c.Specify("Malformed expressions are errors.", func() {
+    _, err := context.Parse("")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Parse("+ 1")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Parse("[1 2")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Parse("]")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func EvalNodeSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("rev3", func(a, b, c int) (int, int, int) { return c, b, a })
+  c.Specify("Subtrees can be evaluated directly.", func() {
+    n, err := context.Parse("+ 1 * 2 - 7 4")
+    c.Assume(err, Equals, nil)
+    res, err := context.EvalNode(n)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 7)
+    res, err = context.EvalNode(n.Children[1])
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 6)
+    res, err = context.EvalNode(n.Children[1].Children[1])
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    c.Expect(n.Children[1].String(), Equals, "* 2 - 7 4")
+  })
+  c.Specify("Subtrees producing several values keep them all.", func() {
+    n, err := context.Parse("- rev3 1 2 3")
+    c.Assume(err, Equals, nil)
+    res, err := context.EvalNode(n.Children[0])
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 3)
+  })
+  c.Specify("Hand built nodes can be evaluated.", func() {
+    n := &polish.Node{Term: "*", Children: []*polish.Node{{Term: "6"}, {Term: "[", Children: nil}}}
+    _, err := context.EvalNode(n)
+    c.Expect(err, Not(Equals), nil)
+    n.Children[1] = &polish.Node{Term: "7"}
+    res, err := context.EvalNode(n)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 42)
+  })
+}
+
+func CheckSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("rev3", func(a, b, c int) (int, int, int) { return c, b, a })
+  context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+  c.Specify("Well-formed expressions pass.", func() {
+    c.Expect(context.Check("+ 1 * 2 3"), Equals, nil)
+    c.Expect(context.Check("+ makeTwo"), Equals, nil)
+    c.Expect(context.Check("rev3 1 2 3"), Equals, nil)
+    c.Expect(context.Check("[ 1 2 makeTwo ]"), Equals, nil)
+  })
+  c.Specify("Missing arguments are reported with their position.", func() {
+    err := context.Check("+ 1 * 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'*' at term 3"), Equals, true)
+  })
+  c.Specify("Values passing to an outer function are reported.", func() {
+    // Eval accepts this, the surplus value from makeTwo goes to the outer +.
+    _, err := context.Eval("+ - 1 makeTwo")
+    c.Assume(err, Equals, nil)
+    err = context.Check("+ - 1 makeTwo")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'-' at term 2"), Equals, true)
+  })
+  c.Specify("Unused terms are reported.", func() {
+    err := context.Check("+ 1 2 3")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "Term 4 ('3')"), Equals, true)
+  })
+  c.Specify("Unbalanced brackets are reported.", func() {
+    c.Expect(context.Check("[ 1 2"), Not(Equals), nil)
+    c.Expect(context.Check("]"), Not(Equals), nil)
+  })
+}
+
+func ValidateSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  calls := 0
+  context.AddFunc("count", func(a int) int { calls++; return a })
+  c.Specify("Each expression gets its own error, nil if it is valid.", func() {
+    errs := context.Validate([]string{"+ 1 2", "+ 1", "count 3", "+ 1 2 3", "* x y"})
+    c.Assume(len(errs), Equals, 5)
+    c.Expect(errs[0], Equals, nil)
+    c.Expect(errs[1], Not(Equals), nil)
+    c.Expect(errs[2], Equals, nil)
+    c.Expect(errs[3], Not(Equals), nil)
+    c.Expect(errs[4], Equals, nil)
+    c.Expect(calls, Equals, 0)
+  })
+  c.Specify("No expressions give no errors.", func() {
+    c.Expect(len(context.Validate(nil)), Equals, 0)
+  })
+}
+
+func IsCompleteSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+  expectComplete := func(expression string, expected bool) {
+    complete, err := context.IsComplete(expression)
+    c.Expect(err, Equals, nil)
+    c.Expect(complete, Equals, expected)
+  }
+  c.Specify("Fully applied expressions are complete.", func() {
+    expectComplete("+ 1 2", true)
+    expectComplete("+ makeTwo", true)
+    expectComplete("[ 1 2 ]", true)
+    expectComplete("bind (a b) makeTwo + a b", true)
+  })
+  c.Specify("Expressions that need more terms are incomplete.", func() {
+    expectComplete("", false)
+    expectComplete("+", false)
+    expectComplete("+ 1", false)
+    expectComplete("* 2 + 1", false)
+    expectComplete("[ 1 2", false)
+    expectComplete("bind (a b", false)
+    expectComplete("bind (a b) makeTwo", false)
+  })
+  c.Specify("Malformed expressions are errors.", func() {
+    for _, expression := range []string{"]", "+ 1 ]", "bind (a) makeTwo a", "bind a 1 a"} {
+      complete, err := context.IsComplete(expression)
+      c.Expect(complete, Equals, false)
+      c.Expect(err, Not(Equals), nil)
+    }
+  })
+}
+
+func CommentSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.SetComments(true)
+  source := "# total\n+ 1 # first\n# still first\n* 2 3 # done"
+  c.Specify("Comments are ignored by Eval.", func() {
+    res, err := context.Eval(source)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 7)
+  })
+  c.Specify("Comments are kept on the Nodes they precede.", func() {
+    n, err := context.Parse(source)
+    c.Assume(err, Equals, nil)
+    c.Expect(n.Comments, ContainsExactly, []string{"# total"})
+    c.Expect(n.Children[1].Comments, ContainsExactly, []string{"# first", "# still first"})
+    c.Expect(n.Trailing, ContainsExactly, []string{"# done"})
+    c.Expect(len(n.Children[0].Comments), Equals, 0)
+  })
+  c.Specify("Formatting a parsed expression reproduces its comments.", func() {
+    n, err := context.Parse(source)
+    c.Assume(err, Equals, nil)
+    formatted := n.String()
+    c.Expect(formatted, Equals, "# total\n+ 1 # first\n# still first\n* 2 3 # done")
+    again, err := context.Parse(formatted)
+    c.Assume(err, Equals, nil)
+    c.Expect(again.String(), Equals, formatted)
+    res, err := context.EvalNode(n)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 7)
+  })
+  c.Specify("Without comments enabled # is an ordinary rune.", func() {
+    plain := polish.MakeContext()
+    plain.AddFunc("#", func(a int) int { return -a })
+    res, err := plain.Eval("# 3")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, -3)
+  })
+}
+
+func WalkSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  c.Specify("Nodes are visited in pre-order.", func() {
+    root, err := context.Parse("+ * 2 3 [ 4 5 ]")
+    c.Assume(err, Equals, nil)
+    var terms []string
+    root.Walk(func(n *polish.Node) bool {
+      terms = append(terms, n.Term)
+      return true
+    })
+    c.Expect(strings.Join(terms, " "), Equals, "+ * 2 3 [ 4 5")
+  })
+  c.Specify("Returning false skips the children.", func() {
+    root, err := context.Parse("+ * 2 3 - 4 5")
+    c.Assume(err, Equals, nil)
+    var terms []string
+    root.Walk(func(n *polish.Node) bool {
+      terms = append(terms, n.Term)
+      return n.Term != "*"
+    })
+    c.Expect(strings.Join(terms, " "), Equals, "+ * - 4 5")
+  })
+  c.Specify("Subtrees can be rewritten during the walk.", func() {
+    root, err := context.Parse("+ * 2 3 * 4 5")
+    c.Assume(err, Equals, nil)
+    root.Walk(func(n *polish.Node) bool {
+      if n.Term == "*" {
+        n.Term = "-"
+      }
+      if n.Term == "5" {
+        n.Term = "1"
+      }
+      return true
+    })
+    c.Expect(root.String(), Equals, "+ - 2 3 - 4 1")
+    res, err := context.EvalNode(root)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 2)
+  })
+}
+
+func SubstituteSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.SetValue("y", 5)
+  c.Specify("A variable can be replaced by a subexpression.", func() {
+    root, err := context.Parse("+ x * x 3")
+    c.Assume(err, Equals, nil)
+    replacement, err := context.Parse("* 2 y")
+    c.Assume(err, Equals, nil)
+    sub := root.Substitute("x", replacement)
+    c.Expect(sub.String(), Equals, "+ * 2 y * * 2 y 3")
+    res, err := context.EvalNode(sub)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 40)
+    res, err = context.Eval(sub.String())
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 40)
+  })
+  c.Specify("The original tree and the replacement are left unchanged.", func() {
+    root, err := context.Parse("+ x 1")
+    c.Assume(err, Equals, nil)
+    replacement := &polish.Node{Term: "7"}
+    sub := root.Substitute("x", replacement)
+    sub.Children[0].Term = "8"
+    c.Expect(root.String(), Equals, "+ x 1")
+    c.Expect(replacement.Term, Equals, "7")
+  })
+  c.Specify("Functions and the terms of token functions are left alone.", func() {
+    context.AddFunc("seven", func() int { return 7 })
+    context.AddTokenFunc("quote", func(cursor polish.TokenCursor) ([]reflect.Value, int, error) {
+      term, _ := cursor.Peek(0)
+      return []reflect.Value{reflect.ValueOf(term)}, 1, nil
+    })
+    root, err := context.Parse("+ seven seven")
+    c.Assume(err, Equals, nil)
+    sub := root.Substitute("seven", &polish.Node{Term: "1"})
+    c.Expect(sub.String(), Equals, "+ seven seven")
+    res, err := context.EvalNode(sub)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 14)
+    root, err = context.Parse("quote x")
+    c.Assume(err, Equals, nil)
+    c.Expect(root.Substitute("x", &polish.Node{Term: "1"}).String(), Equals, "quote x")
+  })
+  c.Specify("Bound names shadow the variable.", func() {
+    root, err := context.Parse("+ x bind (x) + x 1 * x 2")
+    c.Assume(err, Equals, nil)
+    sub := root.Substitute("x", &polish.Node{Term: "10"})
+    c.Expect(sub.String(), Equals, "+ 10 bind ( x ) + 10 1 * x 2")
+    res, err := context.EvalNode(sub)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 32)
+  })
+}
+
+func EqualSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  context.SetValue("x", 2.0)
+  expectEqual := func(a, b string, expected bool) {
+    eq, err := context.Equal(a, b)
+    c.Assume(err, Equals, nil)
+    c.Expect(eq, Equals, expected)
+  }
+  c.Specify("Spacing does not matter.", func() {
+    expectEqual("+ 1.0 2.0", "+  1.0   2.0", true)
+    expectEqual("+ 1.0 2.0", "+\t1.0\n2.0", true)
+    expectEqual("[1.0 2.0]", "[ 1.0 2.0 ]", true)
+  })
+  c.Specify("Literals are compared by value.", func() {
+    expectEqual("* x 1.0", "* x 1.00", true)
+    expectEqual("* x 1.5", "* x 15e-1", true)
+    expectEqual("* x 1.0", "* x 1.5", false)
+    expectEqual("* x 1", "* x 1.0", false)
+  })
+  c.Specify("Structure must match exactly.", func() {
+    expectEqual("+ 1.0 2.0", "+ 2.0 1.0", false)
+    expectEqual("+ 1.0 * 2.0 x", "+ * 1.0 2.0 x", false)
+    expectEqual("- x x", "+ x x", false)
+    expectEqual("bind (a) x a", "bind (b) x b", false)
+    expectEqual("bind (a) x a", "bind ( a ) x a", true)
+  })
+  c.Specify("Comments are ignored.", func() {
+    context.SetComments(true)
+    defer context.SetComments(false)
+    expectEqual("+ x # sum\n1.0", "+ x 1.0", true)
+  })
+  c.Specify("Expressions that cannot be parsed are errors.", func() {
+    _, err := context.Equal("+ 1.0", "+ 1.0 2.0")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Equal("x", "]")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
@@ -5,8 +5,16 @@ import (
   "fmt"
   "strconv"
   "reflect"
+  "sync"
   "math"
+  "math/big"
+  "runtime"
   "runtime/debug"
+  "sort"
+  "time"
+  "errors"
+  "unicode"
+  "unicode/utf8"
 )
 
 type Error struct {
@@ -14,18 +22,58 @@ type Error struct {
 
   // Stack trace where the error occurred, if available
   Stack []byte
+
+  // If a function panicked with an error, that error, so that it can be
+  // found with errors.Is and errors.As.  ErrTimeout for EvalTimeout.
+  Err error
 }
 
+// The error wrapped by the Error that EvalTimeout returns when an evaluation
+// takes too long, use errors.Is to check for it.
+var ErrTimeout = errors.New("evaluation timed out")
+
 func (e *Error) Error() string {
   return e.ErrorString
 }
 
+func (e *Error) Unwrap() error {
+  return e.Err
+}
+
 type function struct {
   // An arbitrary function
   f reflect.Value
 
   // The number of input values for the above function
   num int
+
+  // Whether the function was added with AddPureFunc
+  pure bool
+
+  // Description of the function, if one was given
+  doc string
+
+  // Whether the function was added with AddContextFunc, in which case num
+  // does not include its first parameter
+  ctx bool
+
+  // Whether the function was added by one of the built-in contexts
+  builtin bool
+
+  // Whether the function was added with AddTokenFunc, in which case f is a
+  // TokenFunc
+  token bool
+}
+
+// Returns the types of the parameters that are supplied by terms, which leaves
+// out the *Context of a function added with AddContextFunc.
+func (f function) params() []reflect.Type {
+  typ := f.f.Type()
+  var params []reflect.Type
+  for i := typ.NumIn() - f.num; i < typ.NumIn(); i++ {
+    params = append(params, typ.In(i))
+  }
+  return params
 }
 
 // A Context is used to evaluate Polish notation expressions.  The Context
@@ -43,12 +91,149 @@ type function struct {
 //   v.Float()  // Evaluates to 2 * math.Pi
 //   v,err = c.Eval("* 3.0 - pi e ")
 //   v.Float()  // Evaluates to 3 * (pi - e)
-// Constants are interpreted as int if possible, otherwise float64.
+// Constants are interpreted as int if possible, otherwise float64.  A list of
+// values can be written between brackets, "[1.0 2.0 3.0]" is a []float64.
+//
+// Each term is first looked up as a function, then as a value, and only then
+// parsed as a literal.  A sign is only a sign when it is attached to the
+// number it applies to, so "-3" and "-3.0" are negative literals even when "-"
+// is registered as a function, while "- 3" applies the "-" function to 3.
+//
+// Functions can return other functions, which the apply special form calls:
+//   apply f a1 a2 ...   evaluates f, which must produce a single func value,
+//                       and then takes as many values from the subexpressions
+//                       that follow as that func has parameters, exactly as a
+//                       function added with AddFunc would, and produces its
+//                       results.
+// This allows partial application, for example after
+//   c.AddFunc("adder", func(a int) func(int) int {
+//     return func(b int) int { return a + b }
+//   })
+// apply adder 5 3 is 8, and a func that returns a func can be applied twice, as
+// in apply apply curried 1 2 3.  A func value is otherwise an ordinary value
+// and is never called unless it is given to apply, so funcs can still be
+// passed to functions that take them.  Variadic funcs cannot be applied, since
+// the number of arguments they take is not fixed.  Parse and Check need to
+// know the number of arguments without evaluating anything, so they only
+// accept apply when the type of f can be worked out as TypeCheck would, such
+// as when f is a call to a function added with AddFunc or a value set with
+// SetValue.
 type Context struct {
   funcs map[string]function
   vals  map[string]reflect.Value
-  terms []string
+  lazy  map[string]*lazyValue
   parse_order []Type
+
+  // Values set by WithOverride, these shadow functions and other values.
+  overrides map[string]reflect.Value
+
+  // Runes that separate terms, if empty then any whitespace separates terms.
+  delims string
+
+  // Set by SetTokenizer, replaces the built-in splitting of expressions.
+  tokenizer func(string) ([]string, error)
+
+  // The type that integral-looking literals are parsed as.
+  default_numeric Type
+
+  // Precedence and associativity of binary operators, for use by infix
+  // parsing.
+  operators map[string]operator
+
+  // Called after each function application, if not nil.
+  tracer func(term string, args, results []reflect.Value)
+
+  // Called with the name of each value read during evaluation, if not nil.
+  value_hook func(name string)
+
+  // Consulted for terms that are not functions, values, or literals.
+  resolver func(term string) (reflect.Value, bool)
+
+  // Used for terms that cannot be resolved or parsed, if valid.
+  default_value reflect.Value
+
+  // Digits after the decimal point when rendering floats, or negative for the
+  // shortest representation that round-trips.
+  float_prec int
+
+  // If not empty, the fmt format used when rendering floats.
+  float_format string
+
+  // If set, surplus values are an error rather than being passed to the parent.
+  strict_arity bool
+
+  // If set, only functions added with AddPureFunc may be called.
+  pure_only bool
+
+  // If set, SetValue rejects values that expressions cannot use.
+  strict_values bool
+
+  // If set, integer literals that are out of range are an error.
+  strict_integers bool
+
+  // If set, Compile evaluates constant subexpressions ahead of time.
+  fold bool
+
+  // How expressions are evaluated.
+  engine Engine
+
+  // If set, EvalScript continues past statements that fail.
+  collect_errors bool
+
+  // Largest difference at which the float64 == considers two values equal.
+  float_epsilon float64
+
+  // How the float64 comparisons treat NaN.
+  nan_mode NaNMode
+
+  // If set, trig functions given values outside of their domains panic
+  // rather than producing NaN.
+  domain_errors bool
+
+  // Number of calls to functions added with AddContextFunc that the Context
+  // was passed down through, and the most that are allowed.
+  nesting     int
+  max_nesting int
+
+  // Precision, in bits, of *big.Float literals.
+  big_prec uint
+
+  // If set, # starts a comment that runs to the end of the line.
+  comments bool
+
+  // If set, parentheses can be put around subexpressions.
+  grouping bool
+
+  // Names removed by Disable, which are an error rather than a literal until
+  // a function with that name is added again.
+  disabled map[string]bool
+
+  // Set by SetFormatter, used to render values of particular types.
+  formatters map[reflect.Type]func(reflect.Value) string
+
+  // Results of recent calls to Eval, most recent first, see SetHistorySize.
+  history      []reflect.Value
+  history_size int
+}
+
+// Stats describes the work done while evaluating a single expression.
+type Stats struct {
+  // Number of terms evaluated, including functions, values, and literals.
+  Nodes int
+
+  // Deepest level of nesting reached, a lone term has a depth of 1.
+  MaxDepth int
+
+  // Number of function applications.
+  Calls int
+
+  // Number of times a value set with SetValue was referenced.
+  Lookups int
+}
+
+type operator struct {
+  precedence int
+  right_assoc bool
 }
 
 type Type int
@@ -56,78 +241,431 @@ const(
   Integer Type = iota
   Float
   String
+
+  // Char parses single-quoted rune literals such as 'a' or '\n' as a rune.
+  Char
+
+  // Int64 and Int32 parse integral literals as int64 and int32, failing if
+  // they do not fit.
+  Int64
+  Int32
+
+  // BigFloat parses decimal literals as *big.Float, see
+  // AddBigFloatMathContext.
+  BigFloat
+
+  // FixedPoint parses decimal literals as Decimal, see AddDecimalContext.
+  FixedPoint
 )
 
-func (c *Context) subEval() (vs []reflect.Value, err error) {
-  term := c.terms[0]
-  c.terms = c.terms[1:]
-  if f, ok := c.funcs[term]; ok {
-    var args []reflect.Value
-    for len(args) < f.num {
-      var results []reflect.Value
-      results, err = c.subEval()
-      if err != nil {
-        return
-      }
-      for _, result := range results {
-        args = append(args, result)
+// Returns the name of the Type, such as "Integer", or "Type(n)" if it is not
+// one of the defined Types.
+func (t Type) String() string {
+  switch t {
+  case Integer:
+    return "Integer"
+  case Float:
+    return "Float"
+  case String:
+    return "String"
+  case Char:
+    return "Char"
+  case Int64:
+    return "Int64"
+  case Int32:
+    return "Int32"
+  case BigFloat:
+    return "BigFloat"
+  case FixedPoint:
+    return "FixedPoint"
+  }
+  return fmt.Sprintf("Type(%d)", int(t))
+}
+
+// Parses a term as a literal, trying each Type in the parse order, and returns
+// the value along with the Type that parsed it.  The returned Value is invalid
+// if none of them could parse the term.
+func (c *Context) parseLiteral(term string) (reflect.Value, Type, error) {
+  for _, v := range c.parse_order {
+    val, err := c.parseAs(v, term)
+    if err != nil {
+      return reflect.Value{}, v, err
+    }
+    if val != (reflect.Value{}) {
+      return val, v, nil
+    }
+  }
+  return reflect.Value{}, 0, nil
+}
+
+// Parses a term as a literal of a single Type, returning an invalid Value if
+// it is not one.
+func (c *Context) parseAs(v Type, term string) (reflect.Value, error) {
+  switch v {
+  case Integer:
+    ival, e := strconv.Atoi(term)
+    if e != nil && !errors.Is(e, strconv.ErrRange) {
+      return reflect.Value{}, nil
+    }
+    if c.default_numeric != Integer {
+      // A literal that is out of range for an int may still fit the default.
+      return c.parseAs(c.default_numeric, term)
+    }
+    if e != nil {
+      return reflect.Value{}, c.checkRange(e, term, "int")
+    }
+    return reflect.ValueOf(ival), nil
+
+  case Float:
+    fval, e := strconv.ParseFloat(term, 64)
+    if e == nil {
+      return reflect.ValueOf(fval), nil
+    }
+
+  case String:
+    return reflect.ValueOf(term), nil
+
+  case Char:
+    if len(term) >= 3 && term[0] == '\'' && term[len(term)-1] == '\'' {
+      cval, e := strconv.Unquote(term)
+      if e == nil {
+        return reflect.ValueOf([]rune(cval)[0]), nil
       }
     }
-    var remaining []reflect.Value
-    if len(args) > f.num {
-      remaining = args[f.num:]
-      args = args[0:f.num]
+
+  case Int64:
+    ival, e := strconv.ParseInt(term, 10, 64)
+    if e == nil {
+      return reflect.ValueOf(ival), nil
     }
-    vs = f.f.Call(args)
-    for _, v := range remaining {
-      vs = append(vs, v)
+    return reflect.Value{}, c.checkRange(e, term, "int64")
+
+  case Int32:
+    ival, e := strconv.ParseInt(term, 10, 32)
+    if e == nil {
+      return reflect.ValueOf(int32(ival)), nil
     }
-    return
-  } else if val, ok := c.vals[term]; ok {
-    vs = append(vs, val)
-    return
+    return reflect.Value{}, c.checkRange(e, term, "int32")
+
+  case BigFloat:
+    bval, _, e := big.ParseFloat(term, 10, c.big_prec, big.ToNearestEven)
+    if e == nil {
+      return reflect.ValueOf(bval), nil
+    }
+
+  case FixedPoint:
+    dval, e := ParseDecimal(term)
+    if e == nil {
+      return reflect.ValueOf(dval), nil
+    }
+
+  default:
+    return reflect.Value{}, &Error{fmt.Sprintf("Unknown polish.Type: %v", v), nil, nil}
   }
-  var val reflect.Value
-  for _, v := range c.parse_order {
-    switch v {
-    case Integer:
-      ival, e := strconv.Atoi(term)
-      if e == nil {
-        val = reflect.ValueOf(ival)
+  return reflect.Value{}, nil
+}
+
+// Returns an Error if strict integers are on and e is from parsing an integer
+// that is out of range, rather than one that is not an integer at all.
+func (c *Context) checkRange(e error, term string, typ string) error {
+  if c.strict_integers && errors.Is(e, strconv.ErrRange) {
+    return &Error{fmt.Sprintf("The literal '%s' is out of range for an %s.", term, typ), nil, nil}
+  }
+  return nil
+}
+
+// When set, a literal that looks like an integer but is out of range for the
+// type it would be parsed as, such as 99999999999999999999 for an int, is an
+// Error.  Otherwise it is not parsed as an integer at all, and so becomes
+// whichever type comes next in the parse order, usually a float64 that has
+// lost precision.  This applies to Integer, Int64 and Int32, including when
+// Integer literals are parsed as Int64 or Int32 by SetDefaultNumeric, in which
+// case the range is that of the default.  If the default is Float, Integer
+// literals are parsed as float64s and are never out of range.
+func (c *Context) SetStrictIntegers(strict bool) {
+  c.strict_integers = strict
+}
+
+func (c *Context) isDelim(r rune) bool {
+  if c.delims == "" {
+    return unicode.IsSpace(r)
+  }
+  return strings.ContainsRune(c.delims, r)
+}
+
+// Splits an expression into terms.  Runs of delimiters are collapsed, and a
+// term that starts with a quote extends to the matching quote so that
+// delimiters can appear inside of quoted literals like ' '.  Brackets are
+// always terms on their own, so "[1 2]" is the same as "[ 1 2 ]".  Comments
+// are dropped, see SetComments.  If SetTokenizer has been used then that
+// tokenizer is used instead.
+func (c *Context) tokenize(expression string) ([]string, error) {
+  terms, _, err := c.tokenizeComments(expression)
+  return terms, err
+}
+
+// Splits an expression into terms like tokenize, and also returns any
+// comments, keyed by the index of the term that follows them.  Comments after
+// the last term are keyed by len(terms).  A custom tokenizer never produces
+// comments.
+func (c *Context) tokenizeComments(expression string) ([]string, map[int][]string, error) {
+  if c.tokenizer != nil {
+    terms, err := c.tokenizer(expression)
+    if err != nil {
+      return nil, nil, &Error{fmt.Sprintf("Failed to tokenize (%s): %v.", expression, err), nil, err}
+    }
+    for i, term := range terms {
+      if term == "" {
+        return nil, nil, &Error{fmt.Sprintf("Failed to tokenize (%s): the tokenizer produced an empty term at position %d.", expression, i+1), nil, nil}
       }
+    }
+    return terms, nil, nil
+  }
+  return splitTerms(c, expression)
+}
 
-    case Float:
-      fval, e := strconv.ParseFloat(term, 64)
-      if e == nil {
-        val = reflect.ValueOf(fval)
+// The built-in tokenizer, which is shared by tokenizeComments and EvalBytes so
+// that strings and byte slices are split in exactly the same way.  Each term
+// is copied out of a byte slice, so the terms never refer to the caller's
+// buffer.
+func splitTerms[S string | []byte](c *Context, expression S) ([]string, map[int][]string, error) {
+  var terms []string
+  var comments map[int][]string
+  start := -1
+  var quote rune
+  escaped := false
+  comment := false
+  var r rune
+  for i, size := 0, 0; i < len(expression); i += size {
+    r, size = decodeRune(expression, i)
+    if comment {
+      if r == '\n' {
+        if comments == nil {
+          comments = make(map[int][]string)
+        }
+        comments[len(terms)] = append(comments[len(terms)], string(expression[start:i]))
+        comment = false
+        start = -1
       }
+      continue
+    }
+    if quote != 0 {
+      switch {
+      case escaped:
+        escaped = false
+      case r == '\\':
+        escaped = true
+      case r == quote:
+        quote = 0
+      }
+      continue
+    }
+    bracket := r == '[' || r == ']' || c.grouping && (r == '(' || r == ')')
+    if c.isDelim(r) || bracket {
+      if start != -1 {
+        terms = append(terms, string(expression[start:i]))
+        start = -1
+      }
+      if bracket {
+        terms = append(terms, string(r))
+      }
+      continue
+    }
+    if start == -1 {
+      start = i
+      if r == '\'' || r == '"' {
+        quote = r
+      }
+      if r == '#' && c.comments {
+        comment = true
+      }
+    }
+  }
+  if start != -1 {
+    if comment {
+      if comments == nil {
+        comments = make(map[int][]string)
+      }
+      comments[len(terms)] = append(comments[len(terms)], string(expression[start:]))
+    } else {
+      terms = append(terms, string(expression[start:]))
+    }
+  }
+  if c.grouping {
+    return c.ungroup(terms, comments)
+  }
+  return terms, comments, nil
+}
 
-    case String:
-      val = reflect.ValueOf(term)
+// Decodes the rune that starts at s[i] and returns it with its width, exactly
+// as ranging over a string would, including for invalid UTF-8.
+func decodeRune[S string | []byte](s S, i int) (rune, int) {
+  if s[i] < utf8.RuneSelf {
+    return rune(s[i]), 1
+  }
+  end := i + utf8.UTFMax
+  if end > len(s) {
+    end = len(s)
+  }
+  return utf8.DecodeRuneInString(string(s[i:end]))
+}
 
+// Removes the parentheses that SetGrouping allows, after checking that they
+// are balanced and that each pair encloses exactly one complete
+// subexpression.  The parentheses around the names of a bind are kept.
+// comments are rekeyed to match the terms that remain.
+func (c *Context) ungroup(terms []string, comments map[int][]string) ([]string, map[int][]string, error) {
+  var out []string
+  // For each term, the number of terms kept before it.
+  kept := make([]int, len(terms)+1)
+  // Positions of the unmatched ( in terms, and of what follows them in out.
+  var opened, starts []int
+  names := false
+  for i, term := range terms {
+    kept[i] = len(out)
+    switch {
+    case names:
+      names = term != ")"
+      out = append(out, term)
+    case term == "(" && i > 0 && terms[i-1] == "bind":
+      names = true
+      out = append(out, term)
+    case term == "(":
+      opened = append(opened, i)
+      starts = append(starts, len(out))
+    case term == ")":
+      if len(opened) == 0 {
+        return nil, nil, &Error{fmt.Sprintf("Found ')' at term %d without a matching '('.", i+1), nil, nil}
+      }
+      pos, start := opened[len(opened)-1], starts[len(starts)-1]
+      opened, starts = opened[:len(opened)-1], starts[:len(starts)-1]
+      if err := c.checkGroup(pos, out[start:]); err != nil {
+        return nil, nil, err
+      }
     default:
-      return nil, &Error{fmt.Sprintf("Unknown polish.Value: %v", v), nil}
+      out = append(out, term)
     }
-    if val != (reflect.Value{}) {
-      break
+  }
+  kept[len(terms)] = len(out)
+  if len(opened) > 0 {
+    return nil, nil, &Error{fmt.Sprintf("Found '(' at term %d without a matching ')'.", opened[len(opened)-1]+1), nil, nil}
+  }
+  var rekeyed map[int][]string
+  for i := range kept {
+    if len(comments[i]) > 0 {
+      if rekeyed == nil {
+        rekeyed = make(map[int][]string)
+      }
+      rekeyed[kept[i]] = append(rekeyed[kept[i]], comments[i]...)
     }
   }
-  if val == (reflect.Value{}) {
-    return nil, &Error{fmt.Sprintf("Unable to parse term: '%s'", term), nil}
+  return out, rekeyed, nil
+}
+
+// Checks that the terms inside the parentheses that start at term pos are a
+// single complete subexpression.
+func (c *Context) checkGroup(pos int, terms []string) error {
+  if len(terms) == 0 {
+    return &Error{fmt.Sprintf("Found empty parentheses at term %d.", pos+1), nil, nil}
+  }
+  p := makeParser(c, terms)
+  if _, _, err := p.parse("", 0); err != nil {
+    return &Error{fmt.Sprintf("The parentheses at term %d must enclose a complete subexpression: %v", pos+1, err), nil, nil}
   }
-  vs = append(vs, val)
-  return
+  if len(p.terms) > 0 {
+    return &Error{fmt.Sprintf("The parentheses at term %d must enclose a single subexpression, but '%s' follows the first one.", pos+1, p.terms[0]), nil, nil}
+  }
+  return nil
 }
 
 // Evaluates a Polish notation expression using functions and values that have
-// been specified using AddFunc and SetValue.
+// been specified using AddFunc and SetValue.  Terms are separated by any
+// Unicode whitespace, including tabs and newlines, unless SetDelimiters has
+// been used.
 // Constants are interpreted as int if possible, otherwise float64.
-func (c *Context) Eval(expression string) (vs []reflect.Value, err error) {
+func (c *Context) Eval(expression string) ([]reflect.Value, error) {
+  terms, err := c.tokenize(expression)
+  if err != nil {
+    return nil, err
+  }
+  return c.record(c.evaluate(expression, &evaluation{c: c, terms: terms}))
+}
+
+// Evaluates an expression held in a byte slice exactly like Eval, without
+// converting the whole slice to a string first.  The terms are split directly
+// from expr by the same tokenizer Eval uses, and each one is copied out of it,
+// so expr can be reused as soon as EvalBytes returns.  Copying a term of a
+// single byte, such as + or 2, does not allocate, so this saves the most for
+// expressions made of short terms, or with long comments or runs of
+// delimiters that are never copied at all.  The expression is only converted
+// to a string if it is needed for an error message, or if SetTokenizer has
+// been used, since a custom tokenizer takes a string.
+func (c *Context) EvalBytes(expr []byte) ([]reflect.Value, error) {
+  if c.tokenizer != nil {
+    return c.Eval(string(expr))
+  }
+  terms, _, err := splitTerms(c, expr)
+  if err != nil {
+    return nil, err
+  }
+  return c.record(c.evaluate("", &evaluation{c: c, terms: terms, source: expr}))
+}
+
+// Adds the result of a call to Eval to the history, see SetHistorySize.
+func (c *Context) record(vs []reflect.Value, err error) ([]reflect.Value, error) {
+  if err == nil && len(vs) == 1 && c.history_size > 0 {
+    c.history = append([]reflect.Value{vs[0]}, c.history...)
+    if len(c.history) > c.history_size {
+      c.history = c.history[:c.history_size]
+    }
+  }
+  return vs, err
+}
+
+// Evaluates an expression that has already been split into terms, bypassing
+// the Context's tokenizer entirely.  Each element of tokens is used exactly as
+// given, so the caller is responsible for trimming whitespace and removing
+// empty tokens, and for making [ and ] separate tokens.
+func (c *Context) EvalTokens(tokens []string) ([]reflect.Value, error) {
+  return c.evaluate(strings.Join(tokens, " "), &evaluation{c: c, terms: tokens})
+}
+
+// Evaluates the first complete expression in expr exactly like Eval, and also
+// returns the terms after it that were not used, rather than ignoring them.
+// leftover is empty if every term was used, and it can be passed to
+// EvalTokens to evaluate the next expression, so a sequence of expressions
+// can be evaluated one after another.  Leftover terms are never an error,
+// even with SetStrictArity, although surplus values passed to a function
+// still are.  Terms are only left over once an expression is complete, so
+// the extra values a multi-value function returns are part of results, as
+// with Eval, and they never cause terms to be left over: if f takes no
+// arguments and returns two values then "f 3" yields both of them and leaves
+// "3".  On error, leftover is nil.
+func (c *Context) EvalAll(expr string) (results []reflect.Value, leftover []string, err error) {
+  terms, err := c.tokenize(expr)
+  if err != nil {
+    return nil, nil, err
+  }
+  ev := &evaluation{c: c, terms: terms, keep_leftover: true}
+  results, err = c.evaluate(expr, ev)
+  if err != nil {
+    return nil, nil, err
+  }
+  return results, append([]string{}, ev.terms...), nil
+}
+
+// Runs an evaluation, converting any panics into errors.  expression is only
+// used in error messages.
+func (c *Context) evaluate(expression string, ev *evaluation) (vs []reflect.Value, err error) {
   defer func() {
     if r := recover(); r != nil {
+      if ev.source != nil {
+        expression = string(ev.source)
+      }
       var local_err Error
       if e, ok := r.(error); ok {
         local_err.ErrorString = fmt.Sprintf("Failed to evaluate (%s): %s.", expression, e.Error())
+        local_err.Err = e
       } else {
         local_err.ErrorString = fmt.Sprintf("Failed to evaluate (%s): %v.", expression, r)
       }
@@ -135,36 +673,312 @@ func (c *Context) Eval(expression string) (vs []reflect.Value, err error) {
       err = &local_err
     }
   }()
-  raw_terms := strings.Fields(expression)
-  c.terms = nil
-  for _, term := range raw_terms {
-    if len(term) > 0 {
-      c.terms = append(c.terms, term)
-    }
-  }
-  vs, err = c.subEval()
+  vs, err = ev.run()
   if err != nil {
     return
   }
   return
 }
 
+// Evaluates an expression exactly like Eval, and also reports how much work
+// was done to evaluate it.  The Stats cover only this call.
+func (c *Context) EvalWithStats(expression string) ([]reflect.Value, Stats, error) {
+  var stats Stats
+  terms, err := c.tokenize(expression)
+  if err != nil {
+    return nil, stats, err
+  }
+  vs, err := c.record(c.evaluate(expression, &evaluation{c: c, terms: terms, stats: &stats}))
+  return vs, stats, err
+}
+
+// Evaluates an expression exactly like Eval, but gives up after d and returns
+// an Error wrapping ErrTimeout.  The evaluation runs in its own goroutine and
+// stops before calling any further functions once d has passed, but Go cannot
+// interrupt a function that is already running, so an expensive function
+// keeps running to completion in the background and its result is discarded.
+// Since that goroutine can still be using the Context, the Context should not
+// be modified until it has finished.
+func (c *Context) EvalTimeout(expression string, d time.Duration) ([]reflect.Value, error) {
+  terms, err := c.tokenize(expression)
+  if err != nil {
+    return nil, err
+  }
+  type result struct {
+    vs  []reflect.Value
+    err error
+  }
+  done := make(chan result, 1)
+  ev := &evaluation{c: c, terms: terms, deadline: time.Now().Add(d)}
+  go func() {
+    vs, err := c.evaluate(expression, ev)
+    done <- result{vs, err}
+  }()
+  timer := time.NewTimer(d)
+  defer timer.Stop()
+  select {
+  case r := <-done:
+    // Recorded here rather than in the goroutine, so that an evaluation that
+    // times out never modifies the Context.
+    return c.record(r.vs, r.err)
+  case <-timer.C:
+    return nil, &Error{fmt.Sprintf("Evaluating (%s) took longer than %v.", expression, d), nil, ErrTimeout}
+  }
+}
+
+// Evaluates each of exprs like Eval, using up to workers goroutines at once,
+// and returns their results and errors at the same indices as exprs.  For a
+// failed expression the results are nil and the error is set, otherwise the
+// error is nil.  If workers is less than 1, one is used per CPU.  Unlike Eval,
+// the results are not recorded in the history set up by SetHistorySize, so
+// evaluation only reads from the Context, and this is safe as long as the
+// Context is not modified until it returns and the functions in it are safe to
+// call concurrently.
+func (c *Context) EvalConcurrent(exprs []string, workers int) ([][]reflect.Value, []error) {
+  if workers < 1 {
+    workers = runtime.GOMAXPROCS(0)
+  }
+  results := make([][]reflect.Value, len(exprs))
+  errs := make([]error, len(exprs))
+  indices := make(chan int)
+  var wg sync.WaitGroup
+  for w := 0; w < workers && w < len(exprs); w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range indices {
+        terms, err := c.tokenize(exprs[i])
+        if err != nil {
+          errs[i] = err
+          continue
+        }
+        results[i], errs[i] = c.evaluate(exprs[i], &evaluation{c: c, terms: terms})
+      }
+    }()
+  }
+  for i := range exprs {
+    indices <- i
+  }
+  close(indices)
+  wg.Wait()
+  return results, errs
+}
+
+// Evaluates an expression exactly like Eval, but fails unless it produces
+// exactly n values.
+func (c *Context) EvalN(expression string, n int) ([]reflect.Value, error) {
+  vs, err := c.Eval(expression)
+  if err != nil {
+    return nil, err
+  }
+  if len(vs) != n {
+    return nil, &Error{fmt.Sprintf("Expected (%s) to produce %d value(s), got %d.", expression, n, len(vs)), nil, nil}
+  }
+  return vs, nil
+}
+
 // Adds a function that can be used in future calls to Eval.  Functions cannot
-// be reassigned.
+// be reassigned.  Names may contain any runes, but they are matched against
+// terms byte-for-byte, so any Unicode normalization is up to the caller.
+// Functions may take and return values of any types, and the types of its
+// inputs need not match the types of its outputs, so func(a, b int) float64
+// can feed its result to a function that takes a float64.  Each value must be
+// assignable to the parameter that it is passed to, no conversions are done.
+// A result of type interface{} is replaced by the value it holds, so a
+// function can choose the type of its result.
 func (c *Context) AddFunc(name string, f interface{}) error {
+  return c.addFunc(name, f, false, "")
+}
+
+// Adds the same function under each of names, as if AddFunc had been called
+// once for each name, which is convenient for synonyms like ^ and pow.  This
+// is atomic: if any of the names cannot be used, because it is already a
+// function or value, is reserved, or is repeated in names, then none of them
+// are added and the Error lists every name that conflicted, in sorted order.
+func (c *Context) AddFuncNames(names []string, f interface{}) error {
+  conflicts := make(map[string]bool)
+  for i, name := range names {
+    _, is_func := c.funcs[name]
+    _, is_val := c.lookupValue(name)
+    if is_func || is_val || special_forms[name] {
+      conflicts[name] = true
+    }
+    for _, other := range names[:i] {
+      if other == name {
+        conflicts[name] = true
+      }
+    }
+  }
+  if len(conflicts) > 0 {
+    var sorted []string
+    for name := range conflicts {
+      sorted = append(sorted, name)
+    }
+    sort.Strings(sorted)
+    return &Error{fmt.Sprintf("Tried to add a function under names that cannot be used: '%s'.", strings.Join(sorted, "', '")), nil, nil}
+  }
+  for _, name := range names {
+    if err := c.addFunc(name, f, false, ""); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// Adds a function exactly like AddFunc, along with a description of it that can
+// be retrieved with FuncDoc.
+func (c *Context) AddFuncDoc(name string, f interface{}, doc string) error {
+  return c.addFunc(name, f, false, doc)
+}
+
+// Returns the description of a function, ok is false if there is no function
+// with that name.
+func (c *Context) FuncDoc(name string) (doc string, ok bool) {
+  f, ok := c.funcs[name]
+  return f.doc, ok
+}
+
+// Returns the types of the inputs and outputs of a function, ok is false if
+// there is no function with that name.
+func (c *Context) FuncSignature(name string) (in, out []reflect.Type, ok bool) {
+  f, ok := c.funcs[name]
+  if !ok {
+    return nil, nil, false
+  }
+  typ := f.f.Type()
+  first := 0
+  if f.ctx {
+    first = 1
+  }
+  for i := first; i < typ.NumIn(); i++ {
+    in = append(in, typ.In(i))
+  }
+  for i := 0; i < typ.NumOut(); i++ {
+    out = append(out, typ.Out(i))
+  }
+  return in, out, true
+}
+
+// Returns the names of all of the functions in the Context, sorted so that the
+// order does not depend on map iteration.
+func (c *Context) FuncNames() []string {
+  names := make([]string, 0, len(c.funcs))
+  for name := range c.funcs {
+    names = append(names, name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// Returns the names of all of the values in the Context, including lazy values
+// and any that are currently set by WithOverride, in sorted order.
+func (c *Context) ValueNames() []string {
+  seen := make(map[string]bool)
+  var names []string
+  add := func(name string) {
+    if !seen[name] {
+      seen[name] = true
+      names = append(names, name)
+    }
+  }
+  for name := range c.vals {
+    add(name)
+  }
+  for name := range c.lazy {
+    add(name)
+  }
+  for name := range c.overrides {
+    add(name)
+  }
+  sort.Strings(names)
+  return names
+}
+
+// Adds a function exactly like AddFunc, but marks it as pure.  A pure function
+// has no side effects and its results depend only on its arguments.
+func (c *Context) AddPureFunc(name string, f interface{}) error {
+  return c.addFunc(name, f, true, "")
+}
+
+// Adds a function whose first parameter is a *Context, which is supplied by
+// the evaluator rather than by a term, so func(c *Context, s string) float64
+// takes a single argument in an expression.  This allows functions like eval,
+// which evaluates a string, to use the Context they were called from.  The
+// Context passed is a copy that shares the functions and values of the
+// original, and tracks how deeply such calls are nested; evaluation fails once
+// the nesting exceeds the limit set by SetMaxNesting, which is 100 by default,
+// so a function that evaluates itself cannot recurse forever.  Changes to
+// other settings through the copy only last for the call.
+func (c *Context) AddContextFunc(name string, f interface{}) error {
   typ := reflect.TypeOf(f)
-  if typ.Kind() != reflect.Func {
-    return &Error{fmt.Sprintf("Tried to add a %v instead of a function.", typ), nil}
+  if typ == nil || typ.Kind() != reflect.Func || typ.NumIn() == 0 || typ.In(0) != reflect.TypeOf(c) {
+    return &Error{fmt.Sprintf("Tried to add a %v as the context function '%s', its first parameter must be a *Context.", typ, name), nil, nil}
+  }
+  if err := c.addFunc(name, f, false, ""); err != nil {
+    return err
+  }
+  fn := c.funcs[name]
+  fn.ctx = true
+  fn.num--
+  c.funcs[name] = fn
+  return nil
+}
+
+// Sets the most calls to functions added with AddContextFunc that can be
+// nested inside one another, see AddContextFunc.
+func (c *Context) SetMaxNesting(n int) {
+  c.max_nesting = n
+}
+
+// Used by the built-in contexts, all of whose functions are pure.
+func (c *Context) addBuiltin(name string, f interface{}, doc string) error {
+  if err := c.addFunc(name, f, true, doc); err != nil {
+    return err
+  }
+  fn := c.funcs[name]
+  fn.builtin = true
+  c.funcs[name] = fn
+  return nil
+}
+
+// Returns whether name is a function that was added by one of the built-in
+// contexts, such as AddFloat64MathContext, rather than by AddFunc or one of
+// its variants.  Since functions cannot be reassigned, a name that a built-in
+// context has claimed stays built-in, although WithOverride can still shadow
+// it for a while.  Values, including the constants the built-in contexts
+// set, are not functions and so are never built-in.
+func (c *Context) IsBuiltin(name string) bool {
+  return c.funcs[name].builtin
+}
+
+func (c *Context) addFunc(name string, f interface{}, pure bool, doc string) error {
+  typ := reflect.TypeOf(f)
+  if typ == nil || typ.Kind() != reflect.Func {
+    return &Error{fmt.Sprintf("Tried to add a %v instead of a function.", typ), nil, nil}
+  }
+  if reflect.ValueOf(f).IsNil() {
+    return &Error{fmt.Sprintf("Tried to add a nil %v as the function '%s'.", typ, name), nil, nil}
+  }
+  if special_forms[name] {
+    return &Error{fmt.Sprintf("Tried to add the function '%s', which is a reserved name.", name), nil, nil}
   }
   if _, ok := c.funcs[name]; ok {
-    return &Error{fmt.Sprintf("Tried to add the function '%s' more than once.", name), nil}
+    return &Error{fmt.Sprintf("Tried to add the function '%s' more than once.", name), nil, nil}
   }
-  if _, ok := c.vals[name]; ok {
-    return &Error{fmt.Sprintf("Tried to give the name '%s' to a function and a value.", name), nil}
+  if _, ok := c.overrides[name]; ok {
+    return &Error{fmt.Sprintf("Cannot add the function '%s' because it is currently overridden by WithOverride, restore it first.", name), nil, nil}
+  }
+  _, set := c.vals[name]
+  _, lazy := c.lazy[name]
+  if set || lazy {
+    return &Error{fmt.Sprintf("Cannot add the function '%s' because it is currently a value, remove it with RemoveValue first.", name), nil, nil}
   }
+  delete(c.disabled, name)
   c.funcs[name] = function{
     f:   reflect.ValueOf(f),
     num: reflect.TypeOf(f).NumIn(),
+    pure: pure,
+    doc: doc,
   }
   return nil
 }
@@ -173,28 +987,439 @@ func (c *Context) AddFunc(name string, f interface{}) error {
 // reassigned
 func (c *Context) SetValue(name string, v interface{}) error {
   if _, ok := c.funcs[name]; ok {
-    return &Error{fmt.Sprintf("Tried to give the name '%s' to a function and a value.", name), nil}
+    return &Error{fmt.Sprintf("Cannot set the value '%s' because it is currently a function, remove it with RemoveFunc first.", name), nil, nil}
+  }
+  if special_forms[name] {
+    return &Error{fmt.Sprintf("Tried to set the value '%s', which is a reserved name.", name), nil, nil}
+  }
+  val := reflect.ValueOf(v)
+  if c.strict_values {
+    switch val.Kind() {
+    case reflect.Invalid, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+      return &Error{fmt.Sprintf("Tried to set '%s' to a %v, which cannot be used in expressions.", name, val.Kind()), nil, nil}
+    }
+  }
+  delete(c.lazy, name)
+  c.vals[name] = val
+  return nil
+}
+
+// Removes a function so that its name can be reused, for a value or for a
+// different function.  This works for functions from the built-in contexts
+// too.  Returns an Error if there is no function with that name.
+func (c *Context) RemoveFunc(name string) error {
+  if _, ok := c.funcs[name]; !ok {
+    return &Error{fmt.Sprintf("Cannot remove the function '%s' because there is no such function.", name), nil, nil}
   }
-  c.vals[name] = reflect.ValueOf(v)
+  delete(c.funcs, name)
   return nil
 }
 
+// Removes each of the named functions, if there is one, such as ^ from
+// AddFloat64MathContext for a sandbox that should not allow exponentiation.
+// Unlike RemoveFunc, a disabled name that appears in an expression is an
+// Error saying that it is disabled, rather than being parsed as a literal or
+// passed to the resolver, and names that are not currently functions are
+// disabled as well.  A value set with the same name is still used.  Adding a
+// function with a disabled name enables it again, so Disable should be called
+// after the contexts have been added.
+func (c *Context) Disable(names ...string) {
+  for _, name := range names {
+    delete(c.funcs, name)
+    c.disabled[name] = true
+  }
+}
+
+// Returns an Error for a term that names a function removed by Disable.
+func (c *Context) disabledError(name string) error {
+  return &Error{fmt.Sprintf("'%s' is disabled in this Context.", name), nil, nil}
+}
+
+// Removes a value that was set with SetValue or SetLazyValue so that its name
+// can be reused, for a function or for a different kind of value.  Returns an
+// Error if there is no such value.  Values set by WithOverride are removed by
+// restoring them instead.
+func (c *Context) RemoveValue(name string) error {
+  _, set := c.vals[name]
+  _, lazy := c.lazy[name]
+  if !set && !lazy {
+    return &Error{fmt.Sprintf("Cannot remove the value '%s' because there is no such value.", name), nil, nil}
+  }
+  delete(c.vals, name)
+  delete(c.lazy, name)
+  return nil
+}
+
+// When set, SetValue rejects values that expressions cannot make use of, which
+// are nil, channels, functions, and unsafe pointers.  This catches mistakes
+// when setting up a Context, but is off by default so that advanced users can
+// store anything they like.
+func (c *Context) SetStrictValues(strict bool) {
+  c.strict_values = strict
+}
+
+type lazyValue struct {
+  f    func() interface{}
+  once sync.Once
+  v    reflect.Value
+}
+
+func (l *lazyValue) get() reflect.Value {
+  l.once.Do(func() { l.v = reflect.ValueOf(l.f()) })
+  return l.v
+}
+
+// Sets a value like SetValue, except that f is not called until the first time
+// the value is referenced during evaluation.  The result of f is then kept and
+// used for all later references, so f is called at most once.  This is safe
+// even if the first references happen in concurrent evaluations, f is still
+// only called once and all of them see its result.
+func (c *Context) SetLazyValue(name string, f func() interface{}) error {
+  if _, ok := c.funcs[name]; ok {
+    return &Error{fmt.Sprintf("Cannot set the value '%s' because it is currently a function, remove it with RemoveFunc first.", name), nil, nil}
+  }
+  if special_forms[name] {
+    return &Error{fmt.Sprintf("Tried to set the value '%s', which is a reserved name.", name), nil, nil}
+  }
+  delete(c.vals, name)
+  c.lazy[name] = &lazyValue{f: f}
+  return nil
+}
+
+// Returns the value with the given name, whether it was set with SetValue,
+// SetLazyValue, or WithOverride.
+func (c *Context) lookupValue(name string) (reflect.Value, bool) {
+  if val, ok := c.overrides[name]; ok {
+    return val, true
+  }
+  if val, ok := c.vals[name]; ok {
+    return val, true
+  }
+  if l, ok := c.lazy[name]; ok {
+    return l.get(), true
+  }
+  return c.historyValue(name)
+}
+
+// Sets how many results of previous calls to Eval are kept, so that they can
+// be used in later expressions.  ans is the result of the most recent call,
+// ans1 is the one before that, and so on up to ans followed by n-1.  Only
+// calls that succeed and produce exactly one value are recorded; a call that
+// fails, or produces no values or several, leaves the history unchanged, so
+// nth can be used to record one of several values.  EvalBytes, EvalWithStats
+// and EvalTimeout record their results as Eval does, but other ways of
+// evaluating, such as EvalTokens, EvalAll, EvalConcurrent and Compile, read
+// the history but do not add to it.  The names are only used if they are not
+// already functions or values, and one that refers past the oldest recorded
+// result is not a value at all.  A size of 0, the default, turns the history
+// off and discards it.  Since Eval modifies the Context while the history is
+// on, the Context should not be used to evaluate expressions on several
+// goroutines at once.
+func (c *Context) SetHistorySize(n int) {
+  if n < 0 {
+    n = 0
+  }
+  c.history_size = n
+  if len(c.history) > n {
+    c.history = c.history[:n]
+  }
+}
+
+// Returns the recorded result named by name, which is ans or ans followed by
+// the number of results to go back.
+func (c *Context) historyValue(name string) (reflect.Value, bool) {
+  if len(c.history) == 0 || !strings.HasPrefix(name, "ans") {
+    return reflect.Value{}, false
+  }
+  i := 0
+  if digits := name[len("ans"):]; digits != "" {
+    if digits[0] < '1' || digits[0] > '9' || strings.TrimLeft(digits, "0123456789") != "" {
+      return reflect.Value{}, false
+    }
+    var err error
+    if i, err = strconv.Atoi(digits); err != nil {
+      return reflect.Value{}, false
+    }
+  }
+  if i >= len(c.history) {
+    return reflect.Value{}, false
+  }
+  return c.history[i], true
+}
+
+// Returns whether there is a value with the given name, without computing it
+// if it is a lazy value.
+func (c *Context) hasValue(name string) bool {
+  if _, ok := c.overrides[name]; ok {
+    return true
+  }
+  if _, ok := c.vals[name]; ok {
+    return true
+  }
+  if _, ok := c.lazy[name]; ok {
+    return true
+  }
+  _, ok := c.historyValue(name)
+  return ok
+}
+
+// Returns the function with the given name, unless it is currently
+// overridden by WithOverride.
+func (c *Context) lookupFunc(name string) (function, bool) {
+  if _, ok := c.overrides[name]; ok {
+    return function{}, false
+  }
+  f, ok := c.funcs[name]
+  return f, ok
+}
+
+// Temporarily makes name refer to the value v, even if name is normally a
+// function or a different value.  Calling restore undoes the override,
+// including going back to any earlier override of the same name, so overrides
+// can be nested as long as they are restored in reverse order.  Overrides
+// change the Context itself, so they should not be made while the Context is
+// being used to evaluate expressions on other goroutines.
+func (c *Context) WithOverride(name string, v interface{}) (restore func()) {
+  prev, overridden := c.overrides[name]
+  c.overrides[name] = reflect.ValueOf(v)
+  return func() {
+    if overridden {
+      c.overrides[name] = prev
+    } else {
+      delete(c.overrides, name)
+    }
+  }
+}
+
 // Sets the order in which to attempt to parse terms.  The default order is
-// Integer, Float, String.  You may want to specify that the order should be
+// Integer, Float, Char, String.  You may want to specify that the order should be
 // Float, String, for example, if you always want to deal with floating points
 // without having to always specify a decimal point.
-// String can parse anything, so if it comes before either Integer or Float
+// String can parse anything, so if it comes before Integer, Float, or Char
 // then nothing will ever be parsed as those Types.
 func (c *Context) SetParseOrder(types ...Type) {
   c.parse_order = types
 }
 
+// Sets the type that integral-looking literals like 2 are parsed as, which
+// must be Integer (the default), Float, Int64, or Int32.  With Float, a float64
+// context can be used without writing 2.0 for every constant, and unlike
+// SetParseOrder(Float, String) the parse order is left alone.  Integer literals
+// are converted no matter which functions are registered, so an int context
+// in the same Context will no longer accept literal arguments.  Int64 and
+// Int32 are set by AddInt64MathContext and AddInt32MathContext.
+func (c *Context) SetDefaultNumeric(t Type) error {
+  switch t {
+  case Integer, Float, Int64, Int32:
+  default:
+    return &Error{fmt.Sprintf("Default numeric type must be Integer, Float, Int64, or Int32, not %v.", t), nil, nil}
+  }
+  c.default_numeric = t
+  return nil
+}
+
+// When set, a # at the start of a term begins a comment that runs to the end
+// of the line, so "+ 1 # one\n 2" is the same as "+ 1 2".  Eval ignores
+// comments, while Parse keeps them on the Nodes so that Node.String can
+// reproduce them, which costs some extra work, so comments are off by
+// default and # is an ordinary rune.
+func (c *Context) SetComments(enabled bool) {
+  c.comments = enabled
+}
+
+// When enabled, parentheses can be put around any complete subexpression, as
+// in + (1) (* 2 3), and are then ignored.  Prefix notation never needs them,
+// so grouping is purely cosmetic and cannot change how an expression is
+// evaluated, but it is forgiving of habits from infix notation.  Parentheses
+// are always terms of their own, like brackets, and must be balanced, with
+// each pair enclosing exactly one complete subexpression; otherwise
+// evaluation fails with an Error giving the position of the offending
+// parenthesis, counting terms from 1 with each parenthesis as a term.  The
+// names of a bind still go in parentheses.  Parse, and everything else that
+// tokenizes an expression, drops the parentheses too.  Grouping is off by
+// default so that parentheses can appear in terms, and it has no effect on a
+// tokenizer set with SetTokenizer.
+func (c *Context) SetGrouping(enabled bool) {
+  c.grouping = enabled
+}
+
+// Sets the runes that separate terms in an expression, any rune in delims
+// acts as a separator and runs of separators are collapsed.  Passing an empty
+// string restores the default, which is to separate terms on whitespace.
+func (c *Context) SetDelimiters(delims string) {
+  c.delims = delims
+}
+
+// Sets a function that splits expressions into terms, replacing the built-in
+// tokenizer for Eval and everything else that takes an expression as a
+// string.  Each term it returns is used exactly as given, as with EvalTokens:
+// terms must not be empty or have surrounding whitespace, [ and ] must be
+// terms of their own to be treated as brackets, and quoted literals must be
+// single terms including their quotes.  Delimiters and comments are the
+// tokenizer's responsibility, so SetDelimiters and SetComments have no effect
+// while it is set.  An error from the tokenizer, or an empty term, fails the
+// evaluation, and the Error wraps the tokenizer's error.  Passing nil restores
+// the built-in tokenizer.
+func (c *Context) SetTokenizer(tokenizer func(string) ([]string, error)) {
+  c.tokenizer = tokenizer
+}
+
+// Sets the precedence and associativity of a binary operator for use when
+// parsing infix expressions.  Operators with a higher precedence bind more
+// tightly, and rightAssoc should be set for operators like ^ where a ^ b ^ c
+// means a ^ (b ^ c).  Prefix evaluation ignores this information entirely.
+func (c *Context) SetOperatorInfo(name string, precedence int, rightAssoc bool) {
+  c.operators[name] = operator{precedence, rightAssoc}
+}
+
+// Returns the precedence and associativity set by SetOperatorInfo, ok is false
+// if nothing has been set for name.
+func (c *Context) OperatorInfo(name string) (precedence int, rightAssoc bool, ok bool) {
+  op, ok := c.operators[name]
+  return op.precedence, op.right_assoc, ok
+}
+
+// Sets a function to be called with the name of each value in the Context
+// that is read during evaluation, which includes values set with SetValue,
+// SetLazyValue and WithOverride, and ans from SetHistorySize.  It is called
+// every time a value is read, so a value referenced twice is reported twice,
+// and only for values that are actually read, so a value in a branch that is
+// not taken is not reported.  Names given by bind and the free variables of an
+// Expr are not values in the Context and are not reported, and neither are
+// values that constant folding read when the Expr was compiled.  The hook may
+// be called from several goroutines at once by EvalConcurrent.  Passing nil
+// removes the hook.
+func (c *Context) SetValueAccessHook(hook func(name string)) {
+  c.value_hook = hook
+}
+
+// Sets a function to be called after every function application during
+// evaluation, with the name of the function, the arguments it was given, and
+// the values it returned.  Values left over for the parent are not included in
+// results.  Passing nil removes the tracer.
+func (c *Context) SetTracer(tracer func(term string, args, results []reflect.Value)) {
+  c.tracer = tracer
+}
+
+// Sets a value to use for any term that is not a function or value and could
+// not be parsed as a literal, so that an expression like "+ x y" can be
+// evaluated even if y was never set.  Since String can parse any term this only
+// has an effect if String is not in the parse order, for example after
+// SetParseOrder(Integer, Float).  Passing nil removes the default value.
+func (c *Context) SetDefaultValue(v interface{}) {
+  c.default_value = reflect.ValueOf(v)
+}
+
+// By default, when the arguments of a function produce more values than the
+// function takes, the extra values are passed along to its parent as if they
+// had been returned by the function.  When strict is set, this is an error
+// instead, as are any terms left over after the expression, such as the 3 in
+// "pi 3", which usually means a value was mistaken for a function.
+func (c *Context) SetStrictArity(strict bool) {
+  c.strict_arity = strict
+}
+
+// Sets a function that is asked to provide a value for any term that is not a
+// function or value and could not be parsed as a literal.  If it returns false
+// then the term is handled as if there were no resolver, falling back to the
+// default value, if any, and otherwise failing.  As with SetDefaultValue, this
+// only has an effect if String is not in the parse order.  Passing nil removes
+// the resolver.
+func (c *Context) SetResolver(resolver func(term string) (reflect.Value, bool)) {
+  c.resolver = resolver
+}
+
+// When set, evaluating an expression that calls a function not added with
+// AddPureFunc fails with an Error before the function is called.  This is
+// useful when evaluating untrusted expressions.  All of the functions added by
+// the built-in contexts are pure.
+func (c *Context) SetPureOnly(pure_only bool) {
+  c.pure_only = pure_only
+}
+
+// Sets the tolerance used by the == function from AddFloat64MathContext, so
+// that == a b is true whenever |a - b| <= eps.  The default is 0, which makes
+// == an exact comparison.  === always compares exactly, regardless of eps.
+// Returns an Error if eps is negative or NaN.
+func (c *Context) SetFloatEpsilon(eps float64) error {
+  if !(eps >= 0) {
+    return &Error{fmt.Sprintf("Float epsilon must be non-negative, got %v.", eps), nil, nil}
+  }
+  c.float_epsilon = eps
+  return nil
+}
+
+// A NaNMode decides what the float64 comparisons from AddFloat64MathContext
+// do when either operand is NaN, see SetNaNMode.
+type NaNMode int
+const(
+  // Every comparison involving NaN is false, as in Go, so a NaN is neither
+  // less than, greater than, nor equal to anything, including itself.  This
+  // is the default.
+  NaNUnordered NaNMode = iota
+
+  // Comparing NaN is an error.
+  NaNError
+
+  // NaN is equal to itself and less than every other value, including -Inf,
+  // which is the order sort.Float64s uses.
+  NaNLowest
+)
+
+// Sets how < <= > >= == and === from AddFloat64MathContext treat NaN operands.
+// As with SetFloatEpsilon, the mode is read when a comparison is called.
+// isnan and isinf can be used to guard against NaN explicitly instead.
+func (c *Context) SetNaNMode(mode NaNMode) {
+  c.nan_mode = mode
+}
+
+// Returns a float64 comparison that applies cmp to operands that are not NaN,
+// and the Context's NaNMode to those that are.
+func (c *Context) floatComparison(op string, cmp func(a, b float64) bool) func(a, b float64) bool {
+  return func(a, b float64) bool {
+    if !math.IsNaN(a) && !math.IsNaN(b) {
+      return cmp(a, b)
+    }
+    switch c.nan_mode {
+    case NaNError:
+      panic(fmt.Sprintf("Cannot compare %v and %v with '%s' because NaN is unordered.", a, b, op))
+    case NaNLowest:
+      order := 0
+      if !math.IsNaN(a) {
+        order = 1
+      } else if !math.IsNaN(b) {
+        order = -1
+      }
+      switch op {
+      case "<":
+        return order < 0
+      case "<=":
+        return order <= 0
+      case ">":
+        return order > 0
+      case ">=":
+        return order >= 0
+      }
+      return order == 0
+    }
+    return false
+  }
+}
+
 // Makes a new Context with no functions or values.
 func MakeContext() *Context {
   return &Context{
     funcs: make(map[string]function),
     vals:  make(map[string]reflect.Value),
-    parse_order: []Type{Integer, Float, String},
+    lazy:  make(map[string]*lazyValue),
+    overrides: make(map[string]reflect.Value),
+    operators: make(map[string]operator),
+    formatters: make(map[reflect.Type]func(reflect.Value) string),
+    disabled: make(map[string]bool),
+    parse_order: []Type{Integer, Float, Char, String},
+    default_numeric: Integer,
+    float_prec: 6,
+    max_nesting: 100,
   }
 }
 
@@ -203,60 +1428,199 @@ func MakeContext() *Context {
 //              || (logical or)
 //              ^^ (logical xor)
 //              !  (logical not)
-//   Constants: pi e
+//              -> (implication, -> a b is true unless a is true and b is false)
+//              <-> (equivalence, true if a and b are the same)
+//              nand (not and, ! && a b)
+//              nor  (not or, ! || a b)
+//   Constants: true false
+// Implication is not commutative, -> false true is true but -> true false is
+// false.  nand and nor are each functionally complete on their own.
 func AddBooleanContext(c *Context) {
-  c.AddFunc("&&", func(a, b bool) bool { return a && b })
-  c.AddFunc("||", func(a, b bool) bool { return a || b })
-  c.AddFunc("^^", func(a, b bool) bool { return (a && !b) || (!a && b) })
-  c.AddFunc("!", func(a bool) bool { return !a })
+  c.addBuiltin("&&", func(a, b bool) bool { return a && b }, "Logical and of two bools.")
+  c.addBuiltin("||", func(a, b bool) bool { return a || b }, "Logical or of two bools.")
+  c.addBuiltin("^^", func(a, b bool) bool { return (a && !b) || (!a && b) }, "Logical xor of two bools.")
+  c.addBuiltin("!", func(a bool) bool { return !a }, "Logical not of a bool.")
+  c.addBuiltin("->", func(a, b bool) bool { return !a || b }, "Logical implication, a implies b.")
+  c.addBuiltin("<->", func(a, b bool) bool { return a == b }, "Logical equivalence, a if and only if b.")
+  c.addBuiltin("nand", func(a, b bool) bool { return !(a && b) }, "Logical nand of two bools, false only if both are true.")
+  c.addBuiltin("nor", func(a, b bool) bool { return !(a || b) }, "Logical nor of two bools, true only if both are false.")
+  c.SetValue("true", true)
+  c.SetValue("false", false)
 }
 
 // Adds several operators and constants to the Context, all of which use float64
 // for any numerical values.  
-//   Functions: + - * / ^ ln log2 log10 < <= > >= ==
+//   Functions: + - * / ^ ln log2 log10 abs sign copysign clamp pct isnan isinf
+//              roundto floorto ceilto < <= > >= == ===
 //   Constants: pi e
+// clamp v lo hi bounds v to [lo, hi], and is an error if lo > hi.  roundto x
+// step is the multiple of step nearest to x, step * round(x / step) with
+// halves rounded away from zero, while floorto and ceilto are the nearest
+// multiples below and above x, so floorto -7.0 5.0 is -10.  The sign of step
+// does not matter, and a zero step is an error.  Since x / step is a float64,
+// an x that is a multiple of step in decimal, such as floorto 0.3 0.1, can
+// land on the multiple below.  pct part whole is 100 * part / whole.  sign x
+// is -1, 0 or 1, it is 0 for both zeros and NaN for NaN.  copysign x y is x
+// with the sign of y, as math.Copysign.  == compares within the tolerance set
+// by SetFloatEpsilon, while === is always exact.  Comparisons involving NaN
+// follow SetNaNMode.  Since the tolerance and mode are read when a comparison
+// is called, constant folding uses those in effect when an expression is
+// compiled.
 func AddFloat64MathContext(c *Context) {
-  c.AddFunc("+", func(a, b float64) float64 { return a + b })
-  c.AddFunc("-", func(a, b float64) float64 { return a - b })
-  c.AddFunc("*", func(a, b float64) float64 { return a * b })
-  c.AddFunc("/", func(a, b float64) float64 { return a / b })
-  c.AddFunc("^", math.Pow)
-  c.AddFunc("ln", math.Log)
-  c.AddFunc("log2", math.Log2)
-  c.AddFunc("log10", math.Log10)
-  c.AddFunc("abs", math.Abs)
-  c.AddFunc("<", func(a, b float64) bool { return a < b })
-  c.AddFunc("<=", func(a, b float64) bool { return a <= b })
-  c.AddFunc(">", func(a, b float64) bool { return a > b })
-  c.AddFunc(">=", func(a, b float64) bool { return a >= b })
-  c.AddFunc("==", func(a, b float64) bool { return a == b })
+  c.addBuiltin("+", func(a, b float64) float64 { return a + b }, "Sum of two float64s.")
+  c.addBuiltin("-", func(a, b float64) float64 { return a - b }, "Difference of two float64s, a - b.")
+  c.addBuiltin("*", func(a, b float64) float64 { return a * b }, "Product of two float64s.")
+  c.addBuiltin("/", func(a, b float64) float64 { return a / b }, "Quotient of two float64s, a / b.")
+  c.addBuiltin("^", math.Pow, "a raised to the power b.")
+  c.addBuiltin("ln", math.Log, "Natural logarithm.")
+  c.addBuiltin("log2", math.Log2, "Base 2 logarithm.")
+  c.addBuiltin("log10", math.Log10, "Base 10 logarithm.")
+  c.addBuiltin("abs", math.Abs, "Absolute value.")
+  c.addBuiltin("sign", fSign, "-1, 0 or 1 according to the sign of x, NaN if x is NaN.")
+  c.addBuiltin("copysign", math.Copysign, "x with the sign of y.")
+  c.addBuiltin("clamp", fClamp, "v bounded to the range [lo, hi], lo must not be greater than hi.")
+  c.addBuiltin("roundto", fToMultiple("roundto", math.Round), "The multiple of step nearest to x, step * round(x / step).")
+  c.addBuiltin("floorto", fToMultiple("floorto", math.Floor), "The largest multiple of step that is not greater than x.")
+  c.addBuiltin("ceilto", fToMultiple("ceilto", math.Ceil), "The smallest multiple of step that is not less than x.")
+  c.addBuiltin("pct", func(part, whole float64) float64 { return 100 * part / whole }, "part as a percentage of whole, 100 * part / whole.")
+  c.addBuiltin("isnan", func(a float64) bool { return math.IsNaN(a) }, "True if a is NaN.")
+  c.addBuiltin("isinf", func(a float64) bool { return math.IsInf(a, 0) }, "True if a is positive or negative infinity.")
+  c.addBuiltin("<", c.floatComparison("<", func(a, b float64) bool { return a < b }), "True if a < b.")
+  c.addBuiltin("<=", c.floatComparison("<=", func(a, b float64) bool { return a <= b }), "True if a <= b.")
+  c.addBuiltin(">", c.floatComparison(">", func(a, b float64) bool { return a > b }), "True if a > b.")
+  c.addBuiltin(">=", c.floatComparison(">=", func(a, b float64) bool { return a >= b }), "True if a >= b.")
+  c.addBuiltin("==", c.floatComparison("==", func(a, b float64) bool { return a == b || math.Abs(a-b) <= c.float_epsilon }), "True if a and b are within the Context's float epsilon of each other.")
+  c.addBuiltin("===", c.floatComparison("===", func(a, b float64) bool { return a == b }), "True if a and b are exactly equal.")
   c.SetValue("pi", math.Pi)
   c.SetValue("e", math.E)
 }
 
-func iPow(base, exp int) int {
+// Adds both AddFloat64MathContext and AddBooleanContext to the Context, which
+// is the usual combination for rules that compare floats and combine the
+// results, such as && > x 0.0 < x 1.0.  The two contexts have no names in
+// common, so every function and constant from each of them is available.
+func AddFloat64AndBooleanContext(c *Context) {
+  AddFloat64MathContext(c)
+  AddBooleanContext(c)
+}
+
+func fSign(x float64) float64 {
+  switch {
+  case x > 0:
+    return 1
+  case x < 0:
+    return -1
+  case x == 0:
+    return 0
+  }
+  return x
+}
+
+// Returns a function that rounds x to a multiple of step, using round to round
+// x / step to an integer.
+func fToMultiple(name string, round func(float64) float64) func(x, step float64) float64 {
+  return func(x, step float64) float64 {
+    if step == 0 {
+      panic(fmt.Sprintf("Cannot use '%s' with a step of zero.", name))
+    }
+    step = math.Abs(step)
+    return step * round(x/step)
+  }
+}
+
+func fClamp(v, lo, hi float64) float64 {
+  if lo > hi {
+    panic(fmt.Sprintf("Cannot clamp to the range [%v, %v], the lower bound is greater than the upper bound.", lo, hi))
+  }
+  return math.Max(lo, math.Min(v, hi))
+}
+
+// The integer types that the integer math contexts are built on.
+type integer interface {
+  int | int32 | int64
+}
+
+func intPow[T integer](base, exp T) T {
   if exp < 0 {
     panic("Cannot raise to a negative power when using integer exponentiation.")
   }
-  if exp == 0 {
-    return 1
+  // Exponentiation by squaring, since recursing once per power can overflow
+  // the stack for large exponents.
+  result := T(1)
+  for exp > 0 {
+    if exp&1 == 1 {
+      result *= base
+    }
+    base *= base
+    exp >>= 1
+  }
+  return result
+}
+
+// Division rounding toward negative infinity, rather than toward zero like /.
+func floorDiv[T integer](a, b T) T {
+  if b == 0 {
+    panic("Cannot floor divide by zero.")
+  }
+  q := a / b
+  if a%b != 0 && (a < 0) != (b < 0) {
+    q--
+  }
+  return q
+}
+
+// Quotient and remainder as computed by Go's / and %, so the remainder has
+// the sign of a.
+func iDivMod(a, b int) (int, int) {
+  if b == 0 {
+    panic("Cannot divmod by zero.")
+  }
+  return a / b, a % b
+}
+
+// Euclidean modulus, which is never negative.
+func euclideanMod[T integer](a, b T) T {
+  if b == 0 {
+    panic("Cannot take a modulus of zero.")
+  }
+  r := a % b
+  if r < 0 {
+    if b > 0 {
+      r += b
+    } else {
+      r -= b
+    }
   }
-  return base * iPow(base, exp-1)
+  return r
 }
 
 // Adds several operators to the Context, all of which use int for any numerical
 // values.
-//   Functions: + - * / ^ < <= > >= ==
+//   Functions: + - * / // mod divmod ^ pow abs < <= > >= ==
+// / truncates toward zero like Go's division, while // rounds toward negative
+// infinity, so / -7 2 is -3 and // -7 2 is -4.  mod is the Euclidean modulus,
+// which is always in [0, |b|), so mod -7 2 is 1 and mod 7 -2 is 1.  When b is
+// positive, a == b * (// a b) + (mod a b).  divmod produces two values, the
+// quotient and remainder exactly as Go's / and % give them, so divmod -7 2 is
+// -3 -1; use nth to pick one.  Dividing by zero is an error.
+// ^ only accepts non-negative exponents and always produces an int.  pow
+// accepts any exponent, but converts both operands to float64 and uses
+// math.Pow, so unlike every other function here it produces a float64, e.g.
+// pow 2 -1 is 0.5.
 func AddIntMathContext(c *Context) {
-  c.AddFunc("+", func(a, b int) int { return a + b })
-  c.AddFunc("-", func(a, b int) int { return a - b })
-  c.AddFunc("*", func(a, b int) int { return a * b })
-  c.AddFunc("/", func(a, b int) int { return a / b })
-  c.AddFunc("^", iPow)
-  c.AddFunc("abs", func(a int) int { if a < 0 { return -a }; return a })
-  c.AddFunc("<", func(a, b int) bool { return a < b })
-  c.AddFunc("<=", func(a, b int) bool { return a <= b })
-  c.AddFunc(">", func(a, b int) bool { return a > b })
-  c.AddFunc(">=", func(a, b int) bool { return a >= b })
-  c.AddFunc("==", func(a, b int) bool { return a == b })
+  c.addBuiltin("+", func(a, b int) int { return a + b }, "Sum of two ints.")
+  c.addBuiltin("-", func(a, b int) int { return a - b }, "Difference of two ints, a - b.")
+  c.addBuiltin("*", func(a, b int) int { return a * b }, "Product of two ints.")
+  c.addBuiltin("/", func(a, b int) int { return a / b }, "Quotient of two ints, a / b, truncated toward zero.")
+  c.addBuiltin("//", floorDiv[int], "Quotient of two ints, a / b, rounded toward negative infinity.")
+  c.addBuiltin("mod", euclideanMod[int], "Euclidean modulus of two ints, always in [0, |b|).")
+  c.addBuiltin("divmod", iDivMod, "Quotient and remainder of two ints, a / b and a % b as in Go.")
+  c.addBuiltin("^", intPow[int], "a raised to the power b, b must not be negative.")
+  c.addBuiltin("pow", func(a, b int) float64 { return math.Pow(float64(a), float64(b)) }, "a raised to the power b as a float64, b may be negative.")
+  c.addBuiltin("abs", func(a int) int { if a < 0 { return -a }; return a }, "Absolute value.")
+  c.addBuiltin("<", func(a, b int) bool { return a < b }, "True if a < b.")
+  c.addBuiltin("<=", func(a, b int) bool { return a <= b }, "True if a <= b.")
+  c.addBuiltin(">", func(a, b int) bool { return a > b }, "True if a > b.")
+  c.addBuiltin(">=", func(a, b int) bool { return a >= b }, "True if a >= b.")
+  c.addBuiltin("==", func(a, b int) bool { return a == b }, "True if a == b.")
 }
@@ -3,8 +3,14 @@ package polish_test
 import (
   . "github.com/orfjackal/gospec/src/gospec"
   "github.com/orfjackal/gospec/src/gospec"
+  "errors"
+  "fmt"
   "math"
   "github.com/runningwild/polish"
+  "reflect"
+  "strings"
+  "sync/atomic"
+  "time"
 )
 
 func Float64ContextSpec(c gospec.Context) {
@@ -23,6 +29,35 @@ func Float64ContextSpec(c gospec.Context) {
   })
 }
 
+func NandNorSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddBooleanContext(context)
+  expectBool := func(expression string, expected bool) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Bool(), Equals, expected)
+  }
+  c.Specify("nand is false only when both are true.", func() {
+    expectBool("nand false false", true)
+    expectBool("nand false true", true)
+    expectBool("nand true false", true)
+    expectBool("nand true true", false)
+  })
+  c.Specify("nor is true only when both are false.", func() {
+    expectBool("nor false false", true)
+    expectBool("nor false true", false)
+    expectBool("nor true false", false)
+    expectBool("nor true true", false)
+  })
+  c.Specify("nand alone can express the other operators.", func() {
+    // ! a is nand a a, and || a b is nand ! a ! b.
+    expectBool("nand true true", false)
+    expectBool("nand nand false false nand true true", true)
+    expectBool("nand nand false false nand false false", false)
+  })
+}
+
 func Float64AndBooleanContextSpec(c gospec.Context) {
   c.Specify("Boolean context works properly with a float64 context.", func() {
     context := polish.MakeContext()
@@ -45,6 +80,21 @@ func Float64AndBooleanContextSpec(c gospec.Context) {
     c.Assume(err, Equals, nil)
     c.Expect(res[0].Bool(), Equals, true)
   })
+  c.Specify("Both contexts can be added with a single call.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64AndBooleanContext(context)
+    context.SetValue("x", 0.25)
+    res, err := context.Eval("&& > x 0.0 < x 1.0")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Bool(), Equals, true)
+    res, err = context.Eval("|| < * x pi 0.0 -> true false")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, false)
+    res, err = context.Eval("* e 1.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, math.E)
+  })
 }
 
 func IntContextSpec(c gospec.Context) {
@@ -94,6 +144,42 @@ func MultiValueReturnSpec(c gospec.Context) {
     // -10
     c.Expect(int(res[0].Int()), Equals, -10)
   })
+  c.Specify("Surplus values are an error with strict arity.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("rev3", func(a, b, c int) (int, int, int) { return c, b, a })
+    context.SetStrictArity(true)
+    _, err := context.Eval("- rev3 1 2 3")
+    c.Expect(err, Not(Equals), nil)
+    res, err := context.Eval("rev3 1 2 3")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 3)
+    context.SetStrictArity(false)
+    res, err = context.Eval("- rev3 1 2 3")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 2)
+  })
+  c.Specify("Unused terms are an error with strict arity.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    res, err := context.Eval("pi 3.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 1)
+    context.SetStrictArity(true)
+    _, err = context.Eval("pi 3.0")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'pi' is a value, not a function"), Equals, true)
+    c.Expect(strings.Contains(err.Error(), "'3.0'"), Equals, true)
+    _, err = context.Eval("+ 1.0 2.0 3.0")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "ends before '3.0'"), Equals, true)
+    context.SetEngine(polish.Iterative)
+    _, err = context.Eval("pi 3.0")
+    c.Expect(err, Not(Equals), nil)
+    res, err = context.Eval("pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 1)
+  })
 }
 
 func ErrorSpec(c gospec.Context) {
@@ -109,6 +195,34 @@ func ErrorSpec(c gospec.Context) {
     _, err := context.Eval("panic")
     c.Assume(err.Error(), Not(Equals), nil)
   })
+  c.Specify("Errors that functions panic with are preserved.", func() {
+    context := polish.MakeContext()
+    context.AddFunc("fail", func() { panic(&codeError{42}) })
+    _, err := context.Eval("fail")
+    c.Assume(err, Not(Equals), nil)
+    var code_err *codeError
+    c.Assume(errors.As(err, &code_err), Equals, true)
+    c.Expect(code_err.code, Equals, 42)
+    c.Expect(strings.Contains(err.Error(), "code 42"), Equals, true)
+    _, ok := err.(*polish.Error)
+    c.Expect(ok, Equals, true)
+  })
+  c.Specify("Other panic values are only in the message.", func() {
+    context := polish.MakeContext()
+    context.AddFunc("panic", func() { panic("rawr") })
+    _, err := context.Eval("panic")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(errors.Unwrap(err), Equals, nil)
+    c.Expect(strings.Contains(err.Error(), "rawr"), Equals, true)
+  })
+}
+
+type codeError struct {
+  code int
+}
+
+func (e *codeError) Error() string {
+  return fmt.Sprintf("code %d", e.code)
 }
 
 func NumRemainingValuesSpec(c gospec.Context) {
@@ -122,6 +236,53 @@ func NumRemainingValuesSpec(c gospec.Context) {
     res, err = context.Eval("makeZero")
     c.Assume(len(res), Equals, 0)
     c.Assume(err, Equals, nil)
+    })
+  c.Specify("Zero-value results between operands are skipped.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("makeZero", func() {})
+    res, err := context.Eval("+ makeZero 1 makeZero makeZero 2")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    _, err = context.Eval("+ 1 makeZero makeZero")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Zero-value results cannot satisfy an argument.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("makeZero", func() {})
+    _, err := context.Eval("+ 1 makeZero")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'+' needs 1 more argument(s), its arguments so far produced 1 value(s)."), Equals, true)
+    c.Expect(strings.Contains(err.Error(), "1 of its 2 argument subexpression(s) produced no values."), Equals, true)
+    _, err = context.Eval("* 2 + 1 makeZero")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'+' needs 1 more argument(s)"), Equals, true)
+    context.SetEngine(polish.Iterative)
+    _, err = context.Eval("+ 1 makeZero")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "1 of its 2 argument subexpression(s) produced no values."), Equals, true)
+  })
+  c.Specify("Multiple values only satisfy as many arguments as they produce.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    calls := 0
+    context.AddFunc("makeTwo", func() (int, int) { calls++; return 1, 2 })
+    context.AddFunc("add3", func(a, b, c int) int { return a + b + c })
+    res, err := context.Eval("- makeTwo makeTwo")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, -1)
+    c.Expect(calls, Equals, 1)
+    res, err = context.Eval("add3 makeTwo makeTwo")
+    c.Assume(len(res), Equals, 2)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 4)
+    c.Expect(int(res[1].Int()), Equals, 2)
+    _, err = context.Eval("add3 makeTwo")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'add3' needs 1 more argument(s), its arguments so far produced 2 value(s)"), Equals, true)
   })
 }
 
@@ -134,6 +295,22 @@ func ParsingSpec(c gospec.Context) {
     c.Assume(err, Equals, nil)
     c.Expect(int(res[0].Int()), Equals, 4)
   })
+  c.Specify("Tabs and newlines separate terms.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    res, err := context.Eval("+\t1\t\t3")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 4)
+    res, err = context.Eval("*\n  + 1 2\n  - 5 1\n")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 12)
+    res, err = context.Eval("\r\n+ \t2\v\f3\u00a0")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 5)
+  })
 }
 
 func ParseOrderSpec(c gospec.Context) {
@@ -155,6 +332,52 @@ func ParseOrderSpec(c gospec.Context) {
   })
 }
 
+func StrictIntegersSpec(c gospec.Context) {
+  c.Specify("Out of range integers become floats by default.", func() {
+    context := polish.MakeContext()
+    res, err := context.Eval("99999999999999999999")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Kind(), Equals, reflect.Float64)
+  })
+  c.Specify("Strict integers make them an error.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetStrictIntegers(true)
+    _, err := context.Eval("+ 99999999999999999999 1")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "The literal '99999999999999999999' is out of range for an int.")
+    _, err = context.Eval("- 1 -99999999999999999999")
+    c.Expect(err, Not(Equals), nil)
+    res, err := context.Eval("+ 9223372036854775806 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Int(), Equals, int64(9223372036854775807))
+    res, err = context.Eval("1.5")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 1.5)
+  })
+  c.Specify("Strict integers apply to the default numeric type.", func() {
+    context := polish.MakeContext()
+    polish.AddInt32MathContext(context)
+    context.SetStrictIntegers(true)
+    _, err := context.Eval("+ 3000000000 1")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "The literal '3000000000' is out of range for an int32.")
+    polish.AddInt64MathContext(context)
+    _, err = context.Eval("+ 9999999999999999999 1")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "The literal '9999999999999999999' is out of range for an int64.")
+  })
+  c.Specify("Strict integers allow large literals when the default is Float.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.SetDefaultNumeric(polish.Float)
+    context.SetStrictIntegers(true)
+    res, err := context.Eval("+ 99999999999999999999 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 1e20)
+  })
+}
+
 func IntOperatorSpec(c gospec.Context) {
   c.Specify("All standard int operators parse.", func() {
     context := polish.MakeContext()
@@ -197,3 +420,1527 @@ func IntOperatorSpec(c gospec.Context) {
     })
   })
 }
+
+func NegativeLiteralSpec(c gospec.Context) {
+  c.Specify("Signed literals parse even when - and + are functions.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    res, err := context.Eval("+ -3 5")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 2)
+    res, err = context.Eval("- -3 +5")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, -8)
+
+    context = polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    res, err = context.Eval("* -3.0 -0.5")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 1.5)
+  })
+  c.Specify("A detached sign is the function, not part of a literal.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    res, err := context.Eval("- 3 5")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, -2)
+  })
+}
+
+func CharLiteralSpec(c gospec.Context) {
+  c.Specify("Single-quoted terms parse as runes.", func() {
+    context := polish.MakeContext()
+    context.AddFunc("upper", func(r rune) rune { return r - 'a' + 'A' })
+    context.AddFunc("isnewline", func(r rune) bool { return r == '\n' })
+    res, err := context.Eval("upper 'q'")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(rune(res[0].Int()), Equals, 'Q')
+    res, err = context.Eval(`isnewline '\n'`)
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+  })
+  c.Specify("Malformed char literals fall through to String.", func() {
+    context := polish.MakeContext()
+    res, err := context.Eval("'ab'")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "'ab'")
+  })
+  c.Specify("String before Char captures char literals.", func() {
+    context := polish.MakeContext()
+    context.SetParseOrder(polish.String, polish.Char)
+    res, err := context.Eval("'a'")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "'a'")
+  })
+}
+
+func DelimiterSpec(c gospec.Context) {
+  c.Specify("Custom delimiters split terms.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetDelimiters(",;")
+    res, err := context.Eval("+,,1;;;2")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    context.SetDelimiters("")
+    res, err = context.Eval("+ 1 2")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+  })
+  c.Specify("Delimiters inside quotes do not split terms.", func() {
+    context := polish.MakeContext()
+    context.AddFunc("isspace", func(r rune) bool { return r == ' ' })
+    res, err := context.Eval("isspace ' '")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+    context.SetDelimiters(",")
+    res, err = context.Eval("isspace,' '")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Bool(), Equals, true)
+  })
+}
+
+func TokenizerSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  // Splits operators from the digits they are glued to, so "+1 2" is "+ 1 2".
+  glued := func(expression string) ([]string, error) {
+    var terms []string
+    for _, field := range strings.Fields(expression) {
+      if strings.ContainsAny(field, "!") {
+        return nil, errors.New("'!' is not allowed")
+      }
+      for len(field) > 1 && strings.ContainsAny(field[:1], "+-*") {
+        terms = append(terms, field[:1])
+        field = field[1:]
+      }
+      terms = append(terms, field)
+    }
+    return terms, nil
+  }
+  c.Specify("A custom tokenizer replaces the built-in one.", func() {
+    context.SetTokenizer(glued)
+    res, err := context.Eval("+1 *2 3")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 7)
+    c.Expect(context.Check("+1 *2 3"), Equals, nil)
+    n, err := context.Parse("+1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.String(), Equals, "+ 1 2")
+  })
+  c.Specify("Tokenizer errors are wrapped.", func() {
+    context.SetTokenizer(glued)
+    _, err := context.Eval("+ 1 !")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'!' is not allowed"), Equals, true)
+    c.Expect(errors.Unwrap(err) != nil, Equals, true)
+    _, err = context.Compile("+1 !")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Empty terms are an error.", func() {
+    context.SetTokenizer(func(string) ([]string, error) { return []string{"+", "", "1"}, nil })
+    _, err := context.Eval("anything")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("nil restores the built-in tokenizer.", func() {
+    context.SetTokenizer(glued)
+    context.SetTokenizer(nil)
+    n, err := context.Parse("+1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.Term, Equals, "+1")
+    res, err := context.Eval("+ 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+  })
+}
+
+func GroupingSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("two", func() (int, int) { return 1, 2 })
+  context.SetGrouping(true)
+  expectInt := func(expression string, expected int) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, expected)
+  }
+  expectError := func(expression, msg string) {
+    _, err := context.Eval(expression)
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, msg)
+  }
+  c.Specify("Parentheses around subexpressions are ignored.", func() {
+    expectInt("+ (1) (* 2 3)", 7)
+    expectInt("(+ 1 (* 2 3))", 7)
+    expectInt("((+ 1 2))", 3)
+    expectInt("+(two)", 3)
+    expectInt("bind (a b) two (+ a b)", 3)
+    expectInt("(bind (a) 4 (* a a))", 16)
+  })
+  c.Specify("Unbalanced parentheses give their position.", func() {
+    expectError("+ (1 2", "Found '(' at term 2 without a matching ')'.")
+    expectError("+ 1) 2", "Found ')' at term 3 without a matching '('.")
+    expectError("+ () 1 2", "Found empty parentheses at term 2.")
+  })
+  c.Specify("Each pair must enclose one complete subexpression.", func() {
+    _, err := context.Eval("(+ 1) 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.HasPrefix(err.Error(), "The parentheses at term 1 must enclose a complete subexpression"), Equals, true)
+    expectError("+ (1 2)", "The parentheses at term 2 must enclose a single subexpression, but '2' follows the first one.")
+  })
+  c.Specify("Parsing drops the parentheses.", func() {
+    n, err := context.Parse("+ (1) (* 2 3)")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.String(), Equals, "+ 1 * 2 3")
+    c.Expect(context.Check("+ (1) (* 2 3)"), Equals, nil)
+  })
+  c.Specify("Comments are kept with the terms that follow them.", func() {
+    context.SetComments(true)
+    defer context.SetComments(false)
+    n, err := context.Parse("+ # one\n(1) ( # two\n2)")
+    c.Assume(err, Equals, nil)
+    c.Expect(n.String(), Equals, "+ # one\n1 # two\n2")
+  })
+  c.Specify("Grouping is off by default.", func() {
+    context := polish.MakeContext()
+    context.AddFunc("id", func(s string) string { return s })
+    res, err := context.Eval("id (1)")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "(1)")
+  })
+}
+
+func UnicodeNameSpec(c gospec.Context) {
+  c.Specify("Functions and values can have non-ASCII names.", func() {
+    context := polish.MakeContext()
+    context.AddFunc("√", math.Sqrt)
+    context.AddFunc("×", func(a, b float64) float64 { return a * b })
+    context.SetValue("π", math.Pi)
+    res, err := context.Eval("√ 16.0")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 4.0)
+    res, err = context.Eval("× π √ 4.0")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 2*math.Pi)
+  })
+  c.Specify("Names are not normalized.", func() {
+    context := polish.MakeContext()
+    context.SetValue("\u00e9", 1)
+    res, err := context.Eval("e\u0301")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "e\u0301")
+  })
+}
+
+func DefaultNumericSpec(c gospec.Context) {
+  c.Specify("Integral literals can default to float64.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    c.Assume(context.SetDefaultNumeric(polish.Float), Equals, nil)
+    res, err := context.Eval("+ 2 * 3 0.5")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 3.5)
+  })
+  c.Specify("Int functions no longer accept float-defaulted literals.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetDefaultNumeric(polish.Float)
+    _, err := context.Eval("+ 1 2")
+    c.Expect(err, Not(Equals), nil)
+    context.SetDefaultNumeric(polish.Integer)
+    res, err := context.Eval("+ 1 2")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+  })
+  c.Specify("Only numeric types can be the default.", func() {
+    context := polish.MakeContext()
+    c.Expect(context.SetDefaultNumeric(polish.String), Not(Equals), nil)
+  })
+}
+
+func OperatorInfoSpec(c gospec.Context) {
+  c.Specify("Operator info is stored and does not affect prefix evaluation.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    _, _, ok := context.OperatorInfo("^")
+    c.Expect(ok, Equals, false)
+    context.SetOperatorInfo("-", 1, false)
+    context.SetOperatorInfo("^", 3, true)
+    prec, right, ok := context.OperatorInfo("^")
+    c.Expect(ok, Equals, true)
+    c.Expect(prec, Equals, 3)
+    c.Expect(right, Equals, true)
+    prec, right, ok = context.OperatorInfo("-")
+    c.Expect(ok, Equals, true)
+    c.Expect(prec, Equals, 1)
+    c.Expect(right, Equals, false)
+    res, err := context.Eval("^ 2 ^ 3 2")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 512)
+  })
+}
+
+func ValueAccessHookSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.SetValue("a", 1)
+  context.SetValue("b", 2)
+  context.SetLazyValue("lazy", func() interface{} { return 3 })
+  var names []string
+  context.SetValueAccessHook(func(name string) { names = append(names, name) })
+  c.Specify("Every value read is reported.", func() {
+    names = nil
+    _, err := context.Eval("+ a * b + a lazy")
+    c.Assume(err, Equals, nil)
+    c.Expect(strings.Join(names, " "), Equals, "a b a lazy")
+  })
+  c.Specify("Literals and bound names are not values in the Context.", func() {
+    names = nil
+    _, err := context.Eval("bind (x) + b 4 * x x")
+    c.Assume(err, Equals, nil)
+    c.Expect(strings.Join(names, " "), Equals, "b")
+    names = nil
+    expr, err := context.Compile("+ a y", "y")
+    c.Assume(err, Equals, nil)
+    _, err = expr.EvalWith(map[string]reflect.Value{"y": reflect.ValueOf(5)})
+    c.Assume(err, Equals, nil)
+    c.Expect(strings.Join(names, " "), Equals, "a")
+  })
+  c.Specify("The hook can be removed.", func() {
+    names = nil
+    context.SetValueAccessHook(nil)
+    _, err := context.Eval("+ a b")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(names), Equals, 0)
+  })
+}
+
+func TracerSpec(c gospec.Context) {
+  c.Specify("The tracer sees each function application in order.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    var steps []string
+    context.SetTracer(func(term string, args, results []reflect.Value) {
+      step := term
+      for _, arg := range args {
+        step += fmt.Sprintf(" %v", arg.Interface())
+      }
+      step += " ="
+      for _, result := range results {
+        step += fmt.Sprintf(" %v", result.Interface())
+      }
+      steps = append(steps, step)
+    })
+    res, err := context.Eval("- * 2 3 + 1 1")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(steps), Equals, 3)
+    c.Expect(steps[0], Equals, "* 2 3 = 6")
+    c.Expect(steps[1], Equals, "+ 1 1 = 2")
+    c.Expect(steps[2], Equals, "- 6 2 = 4")
+
+    steps = nil
+    context.SetTracer(nil)
+    _, err = context.Eval("- * 2 3 + 1 1")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(steps), Equals, 0)
+  })
+}
+
+func StatsSpec(c gospec.Context) {
+  c.Specify("EvalWithStats counts nodes, depth, calls, and lookups.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    res, stats, err := context.EvalWithStats("* 2.0 + pi e")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), IsWithin(1e-9), 2*(math.Pi+math.E))
+    c.Expect(stats.Nodes, Equals, 5)
+    c.Expect(stats.MaxDepth, Equals, 3)
+    c.Expect(stats.Calls, Equals, 2)
+    c.Expect(stats.Lookups, Equals, 2)
+  })
+  c.Specify("Stats only cover a single call.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.EvalWithStats("* 2.0 + pi e")
+    _, stats, err := context.EvalWithStats("pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(stats.Nodes, Equals, 1)
+    c.Expect(stats.MaxDepth, Equals, 1)
+    c.Expect(stats.Calls, Equals, 0)
+    c.Expect(stats.Lookups, Equals, 1)
+  })
+}
+
+func PureOnlySpec(c gospec.Context) {
+  c.Specify("Pure-only contexts reject impure functions.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    called := false
+    context.AddFunc("launch", func(a int) int { called = true; return a })
+    context.AddPureFunc("double", func(a int) int { return 2 * a })
+    context.SetPureOnly(true)
+    res, err := context.Eval("+ double 2 3")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 7)
+    _, err = context.Eval("+ launch 2 3")
+    c.Expect(err, Not(Equals), nil)
+    c.Expect(called, Equals, false)
+
+    context.SetPureOnly(false)
+    _, err = context.Eval("+ launch 2 3")
+    c.Expect(err, Equals, nil)
+    c.Expect(called, Equals, true)
+  })
+}
+
+func DefaultValueSpec(c gospec.Context) {
+  c.Specify("Unresolved terms use the default value.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.SetParseOrder(polish.Float)
+    context.SetValue("x", 2.5)
+    _, err := context.Eval("+ x y")
+    c.Expect(err, Not(Equals), nil)
+    context.SetDefaultValue(0.0)
+    res, err := context.Eval("+ x y")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 2.5)
+    res, err = context.Eval("+ x 1")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 3.5)
+    context.SetDefaultValue(nil)
+    _, err = context.Eval("+ x y")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func UnknownTermSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.SetParseOrder(polish.Integer)
+  c.Specify("A leading unknown term followed by more terms is a function.", func() {
+    _, err := context.Eval("foo 1 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "unknown function 'foo'"), Equals, true)
+  })
+  c.Specify("An unknown argument is a value.", func() {
+    _, err := context.Eval("+ 1 foo")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "unknown value 'foo' for argument 2 of '+'"), Equals, true)
+  })
+  c.Specify("A lone unknown term is a value.", func() {
+    _, err := context.Eval("foo")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "unknown value 'foo'"), Equals, true)
+  })
+}
+
+func FuncDocSpec(c gospec.Context) {
+  c.Specify("Docs can be attached to functions.", func() {
+    context := polish.MakeContext()
+    context.AddFuncDoc("double", func(a int) int { return 2 * a }, "Doubles an int.")
+    context.AddFunc("triple", func(a int) int { return 3 * a })
+    doc, ok := context.FuncDoc("double")
+    c.Expect(ok, Equals, true)
+    c.Expect(doc, Equals, "Doubles an int.")
+    doc, ok = context.FuncDoc("triple")
+    c.Expect(ok, Equals, true)
+    c.Expect(doc, Equals, "")
+    _, ok = context.FuncDoc("quadruple")
+    c.Expect(ok, Equals, false)
+    res, err := context.Eval("double 4")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 8)
+  })
+  c.Specify("Built-in contexts document their functions.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    polish.AddBooleanContext(context)
+    for _, name := range []string{"+", "^", "ln", "==", "&&", "!"} {
+      doc, ok := context.FuncDoc(name)
+      c.Expect(ok, Equals, true)
+      c.Expect(doc, Not(Equals), "")
+    }
+  })
+}
+
+func FuncSignatureSpec(c gospec.Context) {
+  c.Specify("Signatures report input and output types.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("split", func(a float64) (int, float64) { return int(a), a - float64(int(a)) })
+    context.AddFunc("nothing", func() {})
+    in, out, ok := context.FuncSignature("<")
+    c.Assume(ok, Equals, true)
+    c.Assume(len(in), Equals, 2)
+    c.Assume(len(out), Equals, 1)
+    c.Expect(in[0], Equals, reflect.TypeOf(0))
+    c.Expect(in[1], Equals, reflect.TypeOf(0))
+    c.Expect(out[0], Equals, reflect.TypeOf(true))
+    in, out, ok = context.FuncSignature("split")
+    c.Assume(ok, Equals, true)
+    c.Assume(len(in), Equals, 1)
+    c.Assume(len(out), Equals, 2)
+    c.Expect(in[0], Equals, reflect.TypeOf(0.0))
+    c.Expect(out[0], Equals, reflect.TypeOf(0))
+    c.Expect(out[1], Equals, reflect.TypeOf(0.0))
+    in, out, ok = context.FuncSignature("nothing")
+    c.Expect(ok, Equals, true)
+    c.Expect(len(in), Equals, 0)
+    c.Expect(len(out), Equals, 0)
+    _, _, ok = context.FuncSignature("missing")
+    c.Expect(ok, Equals, false)
+  })
+}
+
+func LazyValueSpec(c gospec.Context) {
+  c.Specify("Lazy values are computed once, on first reference.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    calls := 0
+    context.SetLazyValue("table", func() interface{} { calls++; return 42 })
+    _, err := context.Eval("+ 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(calls, Equals, 0)
+    res, err := context.Eval("+ table table")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 84)
+    c.Expect(calls, Equals, 1)
+    res, err = context.Eval("table")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 42)
+    c.Expect(calls, Equals, 1)
+  })
+  c.Specify("Lazy values share a namespace with values.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    c.Expect(context.SetLazyValue("+", func() interface{} { return 1 }), Not(Equals), nil)
+    context.SetLazyValue("x", func() interface{} { return 1 })
+    c.Expect(context.AddFunc("x", func() {}), Not(Equals), nil)
+    context.SetValue("x", 2)
+    res, err := context.Eval("x")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 2)
+  })
+}
+
+func AddFuncErrorSpec(c gospec.Context) {
+  c.Specify("Nil functions are rejected.", func() {
+    context := polish.MakeContext()
+    var fn func()
+    c.Expect(context.AddFunc("x", fn), Not(Equals), nil)
+    c.Expect(context.AddFunc("x", nil), Not(Equals), nil)
+    _, ok := context.FuncDoc("x")
+    c.Expect(ok, Equals, false)
+    c.Expect(context.AddFunc("x", func() {}), Equals, nil)
+  })
+  c.Specify("Non-functions are rejected.", func() {
+    context := polish.MakeContext()
+    c.Expect(context.AddFunc("x", 3), Not(Equals), nil)
+  })
+}
+
+func StrictValuesSpec(c gospec.Context) {
+  c.Specify("Strict values reject unusable kinds.", func() {
+    context := polish.MakeContext()
+    c.Expect(context.SetValue("ch", make(chan int)), Equals, nil)
+    context.SetStrictValues(true)
+    c.Expect(context.SetValue("ch", make(chan int)), Not(Equals), nil)
+    c.Expect(context.SetValue("f", func() {}), Not(Equals), nil)
+    c.Expect(context.SetValue("n", nil), Not(Equals), nil)
+    c.Expect(context.SetValue("x", 1.5), Equals, nil)
+    c.Expect(context.SetValue("v", []float64{1, 2}), Equals, nil)
+    c.Expect(context.SetValue("s", "text"), Equals, nil)
+    c.Expect(context.SetValue("p", &struct{}{}), Equals, nil)
+  })
+}
+
+func HeterogeneousTypesSpec(c gospec.Context) {
+  c.Specify("Results can have different types than their inputs.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.AddFunc("ratio", func(a, b int) float64 { return float64(a) / float64(b) })
+    context.AddFunc("round", func(a float64) int { return int(math.Floor(a + 0.5)) })
+    res, err := context.Eval("+ ratio 1 4 0.5")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 0.75)
+    res, err = context.Eval("ratio round * 2.0 pi round e")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 2.0)
+    _, err = context.Eval("+ ratio 1 4 1")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func ImplicationSpec(c gospec.Context) {
+  c.Specify("Implication and equivalence follow their truth tables.", func() {
+    context := polish.MakeContext()
+    polish.AddBooleanContext(context)
+    expected := map[string]bool{
+      "-> false false":  true,
+      "-> false true":   true,
+      "-> true false":   false,
+      "-> true true":    true,
+      "<-> false false": true,
+      "<-> false true":  false,
+      "<-> true false":  false,
+      "<-> true true":   true,
+    }
+    for expression, value := range expected {
+      res, err := context.Eval(expression)
+      c.Assume(len(res), Equals, 1)
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Bool(), Equals, value)
+    }
+  })
+}
+
+func OverrideSpec(c gospec.Context) {
+  c.Specify("Values can be temporarily overridden.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    restore := context.WithOverride("pi", 3.0)
+    res, err := context.Eval("* 2.0 pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 6.0)
+    inner := context.WithOverride("pi", 4.0)
+    res, err = context.Eval("pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 4.0)
+    inner()
+    res, err = context.Eval("pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 3.0)
+    restore()
+    res, err = context.Eval("pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, math.Pi)
+  })
+  c.Specify("Functions can be temporarily overridden by values.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.AddFunc("scale", func(a float64) float64 { return 10 * a })
+    restore := context.WithOverride("scale", 0.5)
+    res, err := context.Eval("* scale 4.0")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Float(), Equals, 2.0)
+    restore()
+    res, err = context.Eval("scale 4.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 40.0)
+  })
+}
+
+func ResolverSpec(c gospec.Context) {
+  c.Specify("The resolver provides values for unknown terms.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.SetParseOrder(polish.Float)
+    columns := map[string]float64{"price": 2.5, "qty": 4}
+    var asked []string
+    context.SetResolver(func(term string) (reflect.Value, bool) {
+      asked = append(asked, term)
+      v, ok := columns[term]
+      return reflect.ValueOf(v), ok
+    })
+    res, err := context.Eval("* price qty")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 10.0)
+    c.Expect(len(asked), Equals, 2)
+
+    asked = nil
+    res, err = context.Eval("* pi 1.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(len(asked), Equals, 0)
+
+    _, err = context.Eval("* price missing")
+    c.Expect(err, Not(Equals), nil)
+    context.SetDefaultValue(0.0)
+    res, err = context.Eval("+ price missing")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 2.5)
+  })
+}
+
+func EvalNSpec(c gospec.Context) {
+  c.Specify("EvalN requires the expected number of values.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+    context.AddFunc("makeZero", func() {})
+    res, err := context.EvalN("makeTwo", 2)
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 2)
+    res, err = context.EvalN("makeZero", 0)
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 0)
+    res, err = context.EvalN("+ 1 makeTwo", 2)
+    c.Assume(err, Equals, nil)
+    c.Expect(len(res), Equals, 2)
+    _, err = context.EvalN("makeTwo", 1)
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.EvalN("+ 1 2", 2)
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.EvalN("+ 1", 1)
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func FloorDivSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  expectInt := func(expression string, expected int) {
+    res, err := context.Eval(expression)
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, expected)
+  }
+  c.Specify("Floor division rounds toward negative infinity.", func() {
+    expectInt("// 7 2", 3)
+    expectInt("// -7 2", -4)
+    expectInt("// 7 -2", -4)
+    expectInt("// -7 -2", 3)
+    expectInt("// -8 2", -4)
+    expectInt("/ -7 2", -3)
+  })
+  c.Specify("mod is Euclidean.", func() {
+    expectInt("mod 7 2", 1)
+    expectInt("mod -7 2", 1)
+    expectInt("mod 7 -2", 1)
+    expectInt("mod -7 -2", 1)
+    expectInt("mod -8 3", 1)
+    expectInt("mod 6 3", 0)
+    expectInt("+ * 3 // -8 3 mod -8 3", -8)
+  })
+  c.Specify("divmod matches Go's / and %.", func() {
+    res, err := context.Eval("divmod -7 2")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 2)
+    c.Expect(int(res[0].Int()), Equals, -3)
+    c.Expect(int(res[1].Int()), Equals, -1)
+    expectInt("nth 0 divmod 17 5", 3)
+    expectInt("nth 1 divmod 17 5", 2)
+    expectInt("nth 1 divmod 7 -2", 1)
+    expectInt("bind (q r) divmod 23 4 + * q 4 r", 23)
+  })
+  c.Specify("Zero divisors are errors.", func() {
+    _, err := context.Eval("// 1 0")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("mod 1 0")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("divmod 1 0")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func EvalTokensSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  c.Specify("Pre-split tokens are evaluated without the tokenizer.", func() {
+    res, err := context.EvalTokens([]string{"+", "1", "*", "2", "3"})
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 7)
+  })
+  c.Specify("Tokens may contain characters the tokenizer would split on.", func() {
+    context.SetValue("a b", 5)
+    res, err := context.EvalTokens([]string{"*", "a b", "2"})
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 10)
+  })
+  c.Specify("Empty tokens are not trimmed.", func() {
+    _, err := context.EvalTokens([]string{"+", "1", "", "2"})
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Errors are reported as with Eval.", func() {
+    _, err := context.EvalTokens([]string{"+", "1"})
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func EvalTimeoutSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("slow", func(a int) int {
+    time.Sleep(50 * time.Millisecond)
+    return a
+  })
+  c.Specify("Fast evaluations return their results.", func() {
+    res, err := context.EvalTimeout("+ 1 2", time.Second)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    _, err = context.EvalTimeout("+ 1", time.Second)
+    c.Expect(err, Not(Equals), nil)
+    c.Expect(errors.Is(err, polish.ErrTimeout), Equals, false)
+  })
+  c.Specify("Slow evaluations time out.", func() {
+    res, err := context.EvalTimeout("slow 1", 10*time.Millisecond)
+    c.Expect(res == nil, Equals, true)
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(errors.Is(err, polish.ErrTimeout), Equals, true)
+  })
+  c.Specify("No more functions are called after the deadline.", func() {
+    context := polish.MakeContext()
+    var calls int32
+    context.AddFunc("slow", func(a int) int {
+      time.Sleep(50 * time.Millisecond)
+      atomic.AddInt32(&calls, 1)
+      return a
+    })
+    _, err := context.EvalTimeout("slow slow slow 1", 10*time.Millisecond)
+    c.Expect(errors.Is(err, polish.ErrTimeout), Equals, true)
+    time.Sleep(200 * time.Millisecond)
+    c.Expect(atomic.LoadInt32(&calls), Equals, int32(1))
+  })
+}
+
+func EvalConcurrentSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.SetLazyValue("x", func() interface{} { return 10 })
+  exprs := make([]string, 200)
+  for i := range exprs {
+    exprs[i] = fmt.Sprintf("+ x * %d 2", i)
+  }
+  c.Specify("Results line up with their expressions.", func() {
+    for _, workers := range []int{1, 4, 0} {
+      results, errs := context.EvalConcurrent(exprs, workers)
+      c.Assume(len(results), Equals, len(exprs))
+      c.Assume(len(errs), Equals, len(exprs))
+      for i := range exprs {
+        c.Expect(errs[i], Equals, nil)
+        c.Expect(int(results[i][0].Int()), Equals, 10+2*i)
+      }
+    }
+  })
+  c.Specify("Errors line up with their expressions.", func() {
+    results, errs := context.EvalConcurrent([]string{"+ 1 2", "+ 1", "* 2 3", "/ 1 0"}, 3)
+    c.Expect(errs[0], Equals, nil)
+    c.Expect(errs[1], Not(Equals), nil)
+    c.Expect(results[1] == nil, Equals, true)
+    c.Expect(int(results[2][0].Int()), Equals, 6)
+    c.Expect(errs[3], Not(Equals), nil)
+  })
+  c.Specify("Results are not added to the history.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetHistorySize(3)
+    _, err := context.Eval("5")
+    c.Assume(err, Equals, nil)
+    exprs := make([]string, 200)
+    for i := range exprs {
+      exprs[i] = fmt.Sprintf("+ ans %d", i)
+    }
+    results, errs := context.EvalConcurrent(exprs, 4)
+    for i := range exprs {
+      c.Assume(errs[i], Equals, nil)
+      c.Expect(int(results[i][0].Int()), Equals, 5+i)
+    }
+    res, err := context.EvalTokens([]string{"ans"})
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 5)
+    res, err = context.EvalTokens([]string{"ans1"})
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Kind(), Equals, reflect.String)
+  })
+  c.Specify("No expressions produce no results.", func() {
+    results, errs := context.EvalConcurrent(nil, 4)
+    c.Expect(len(results), Equals, 0)
+    c.Expect(len(errs), Equals, 0)
+  })
+}
+
+func HistorySpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("two", func() (int, int) { return 1, 2 })
+  expectInt := func(expression string, expected int) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, expected)
+  }
+  c.Specify("History is off by default.", func() {
+    expectInt("+ 1 2", 3)
+    _, err := context.Eval("+ ans 1")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("ans refers to previous results.", func() {
+    context.SetHistorySize(3)
+    expectInt("+ 1 2", 3)
+    expectInt("* ans 10", 30)
+    expectInt("- ans ans1", 27)
+    expectInt("+ ans2 0", 3)
+    expectInt("ans", 3)
+  })
+  c.Specify("Only the given number of results are kept.", func() {
+    context.SetHistorySize(2)
+    expectInt("1", 1)
+    expectInt("2", 2)
+    expectInt("3", 3)
+    expectInt("ans1", 2)
+    _, err := context.Eval("+ ans2 0")
+    c.Expect(err, Not(Equals), nil)
+    context.SetHistorySize(1)
+    _, err = context.Eval("+ ans1 0")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("EvalWithStats and EvalTimeout record their results.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetHistorySize(3)
+    _, _, err := context.EvalWithStats("+ 1 2")
+    c.Assume(err, Equals, nil)
+    _, err = context.EvalTimeout("* ans 10", time.Second)
+    c.Assume(err, Equals, nil)
+    res, err := context.EvalTokens([]string{"+", "ans", "ans1"})
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 33)
+  })
+  c.Specify("Failures and multiple values are not recorded.", func() {
+    context.SetHistorySize(3)
+    expectInt("7", 7)
+    _, err := context.Eval("+ 1")
+    c.Assume(err, Not(Equals), nil)
+    res, err := context.Eval("two")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 2)
+    expectInt("ans", 7)
+    expectInt("nth 1 two", 2)
+    expectInt("ans1", 7)
+  })
+  c.Specify("Values and functions take precedence.", func() {
+    context.SetHistorySize(3)
+    expectInt("5", 5)
+    context.SetValue("ans", 100)
+    expectInt("ans", 100)
+    expectInt("ans1", 5)
+  })
+  c.Specify("Turning history off discards it.", func() {
+    context.SetHistorySize(3)
+    expectInt("5", 5)
+    context.SetHistorySize(0)
+    context.SetHistorySize(3)
+    expectInt("6", 6)
+    _, err := context.Eval("+ ans1 0")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func EvalAllSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("two", func() (int, int) { return 1, 2 })
+  c.Specify("Leftover terms are returned.", func() {
+    res, leftover, err := context.EvalAll("+ 1 2 * 3 4")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    c.Expect(strings.Join(leftover, " "), Equals, "* 3 4")
+  })
+  c.Specify("Nothing is left over when every term is used.", func() {
+    res, leftover, err := context.EvalAll("+ 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    c.Expect(len(leftover), Equals, 0)
+  })
+  c.Specify("Extra values from a multi-value function are results.", func() {
+    res, leftover, err := context.EvalAll("two 3")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 2)
+    c.Expect(int(res[1].Int()), Equals, 2)
+    c.Expect(leftover, ContainsExactly, []string{"3"})
+  })
+  c.Specify("Leftovers can feed the next evaluation.", func() {
+    sum := 0
+    terms := []string{"+ 1 2 - 10 4 * 2 3"}
+    _, leftover, err := context.EvalAll(terms[0])
+    c.Assume(err, Equals, nil)
+    for len(leftover) > 0 {
+      var res []reflect.Value
+      res, err = context.EvalTokens(leftover[:3])
+      c.Assume(err, Equals, nil)
+      sum += int(res[0].Int())
+      leftover = leftover[3:]
+    }
+    c.Expect(sum, Equals, 12)
+  })
+  c.Specify("Leftover terms are not an error under strict arity.", func() {
+    context.SetStrictArity(true)
+    defer context.SetStrictArity(false)
+    _, leftover, err := context.EvalAll("+ 1 2 3")
+    c.Assume(err, Equals, nil)
+    c.Expect(leftover, ContainsExactly, []string{"3"})
+    _, err = context.Eval("+ 1 2 3")
+    c.Expect(err, Not(Equals), nil)
+    _, _, err = context.EvalAll("+ 1 two")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Errors return no leftovers.", func() {
+    res, leftover, err := context.EvalAll("+ 1")
+    c.Expect(err, Not(Equals), nil)
+    c.Expect(res == nil, Equals, true)
+    c.Expect(leftover == nil, Equals, true)
+  })
+}
+
+func IntPowSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  c.Specify("pow produces a float64 and allows negative exponents.", func() {
+    res, err := context.Eval("pow 2 -1")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Kind(), Equals, reflect.Float64)
+    c.Expect(res[0].Float(), Equals, 0.5)
+    res, err = context.Eval("pow 3 4")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 81.0)
+  })
+  c.Specify("^ still produces an int and rejects negative exponents.", func() {
+    res, err := context.Eval("^ 3 4")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Kind(), Equals, reflect.Int)
+    _, err = context.Eval("^ 2 -1")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func FloatEpsilonSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  expectBool := func(expression string, expected bool) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Bool(), Equals, expected)
+  }
+  c.Specify("== is exact by default.", func() {
+    expectBool("== 0.3 + 0.1 0.2", false)
+    expectBool("== 0.5 0.5", true)
+  })
+  c.Specify("== compares within the float epsilon.", func() {
+    c.Assume(context.SetFloatEpsilon(1e-9), Equals, nil)
+    expectBool("== 0.3 + 0.1 0.2", true)
+    expectBool("== 0.1 + 0.05 0.05", true)
+    expectBool("== 0.1 0.2", false)
+  })
+  c.Specify("=== is always exact.", func() {
+    c.Assume(context.SetFloatEpsilon(1e-9), Equals, nil)
+    expectBool("=== 0.3 + 0.1 0.2", false)
+    expectBool("=== 0.5 0.5", true)
+  })
+  c.Specify("Negative epsilons are rejected.", func() {
+    c.Expect(context.SetFloatEpsilon(-1), Not(Equals), nil)
+    c.Expect(context.SetFloatEpsilon(math.NaN()), Not(Equals), nil)
+  })
+}
+
+func SignSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  context.SetValue("nan", math.NaN())
+  context.SetValue("inf", math.Inf(1))
+  expectFloat := func(expression string, expected float64) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Float(), Equals, expected)
+  }
+  c.Specify("sign is -1, 0 or 1.", func() {
+    expectFloat("sign -2.5", -1)
+    expectFloat("sign 0.0", 0)
+    expectFloat("sign -0.0", 0)
+    expectFloat("sign 1e-300", 1)
+    expectFloat("sign inf", 1)
+    expectFloat("sign - 0.0 inf", -1)
+    res, err := context.Eval("sign -0.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.Signbit(res[0].Float()), Equals, false)
+  })
+  c.Specify("sign of NaN is NaN.", func() {
+    res, err := context.Eval("sign nan")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.IsNaN(res[0].Float()), Equals, true)
+  })
+  c.Specify("copysign takes the sign of its second argument.", func() {
+    expectFloat("copysign 3.0 -1.0", -3)
+    expectFloat("copysign -3.0 2.0", 3)
+    expectFloat("copysign 3.0 -0.0", -3)
+    expectFloat("copysign 3.0 0.0", 3)
+    res, err := context.Eval("copysign nan -1.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.IsNaN(res[0].Float()), Equals, true)
+    c.Expect(math.Signbit(res[0].Float()), Equals, true)
+  })
+  c.Specify("sign composes with abs and clamp.", func() {
+    expectFloat("* sign -4.0 abs -4.0", -4)
+    expectFloat("clamp * 10.0 sign -2.0 -5.0 5.0", -5)
+  })
+}
+
+func NaNSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  context.SetDefaultNumeric(polish.Float)
+  context.SetValue("nan", math.NaN())
+  context.SetValue("inf", math.Inf(1))
+  expectBool := func(expression string, expected bool) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Bool(), Equals, expected)
+  }
+  c.Specify("isnan and isinf detect special values.", func() {
+    expectBool("isnan nan", true)
+    expectBool("isnan - inf inf", true)
+    expectBool("isnan 1", false)
+    expectBool("isinf inf", true)
+    expectBool("isinf - 0 inf", true)
+    expectBool("isinf nan", false)
+    expectBool("isinf 1e308", false)
+  })
+  c.Specify("Comparisons with NaN are false by default.", func() {
+    for _, op := range []string{"<", "<=", ">", ">=", "==", "==="} {
+      expectBool(op+" nan 1", false)
+      expectBool(op+" 1 nan", false)
+      expectBool(op+" nan nan", false)
+    }
+    expectBool("< 1 2", true)
+  })
+  c.Specify("Comparing NaN can be an error.", func() {
+    context.SetNaNMode(polish.NaNError)
+    defer context.SetNaNMode(polish.NaNUnordered)
+    for _, op := range []string{"<", "<=", ">", ">=", "==", "==="} {
+      _, err := context.Eval(op + " nan 1")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval(op + " 1 nan")
+      c.Expect(err, Not(Equals), nil)
+    }
+    expectBool("< 1 2", true)
+    expectBool("isnan nan", true)
+  })
+  c.Specify("NaN can be ordered below everything else.", func() {
+    context.SetNaNMode(polish.NaNLowest)
+    defer context.SetNaNMode(polish.NaNUnordered)
+    expectBool("< nan - 0 inf", true)
+    expectBool("> nan - 0 inf", false)
+    expectBool(">= 1 nan", true)
+    expectBool("<= nan nan", true)
+    expectBool("< nan nan", false)
+    expectBool("== nan nan", true)
+    expectBool("=== nan nan", true)
+    expectBool("== nan 1", false)
+    expectBool("> 2 1", true)
+  })
+}
+
+func TypeStringSpec(c gospec.Context) {
+  c.Specify("Types have readable names.", func() {
+    c.Expect(polish.Integer.String(), Equals, "Integer")
+    c.Expect(polish.Float.String(), Equals, "Float")
+    c.Expect(polish.String.String(), Equals, "String")
+    c.Expect(polish.Char.String(), Equals, "Char")
+    c.Expect(polish.Type(42).String(), Equals, "Type(42)")
+  })
+  c.Specify("Errors mention Types by name.", func() {
+    context := polish.MakeContext()
+    err := context.SetDefaultNumeric(polish.String)
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "not String"), Equals, true)
+    context.SetParseOrder(polish.Type(42))
+    _, err = context.Eval("1")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "Type(42)"), Equals, true)
+  })
+}
+
+func ContextFuncSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  eval := func(ctx *polish.Context, expression string) int {
+    res, err := ctx.Eval(expression)
+    if err != nil {
+      panic(err)
+    }
+    return int(res[0].Int())
+  }
+  c.Assume(context.AddContextFunc("eval", eval), Equals, nil)
+  c.Specify("The Context is supplied as the first argument.", func() {
+    context.SetValue("src", "* 2 3")
+    res, err := context.Eval("+ 1 eval src")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(int(res[0].Int()), Equals, 7)
+  })
+  c.Specify("The Context is not part of the signature.", func() {
+    in, _, ok := context.FuncSignature("eval")
+    c.Assume(ok, Equals, true)
+    c.Expect(len(in), Equals, 1)
+  })
+  c.Specify("Unbounded recursion is an error.", func() {
+    context.SetValue("loop", "eval loop")
+    _, err := context.Eval("eval loop")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("The nesting limit can be set.", func() {
+    context.SetValue("inner", "* 2 3")
+    context.SetValue("outer", "eval inner")
+    _, err := context.Eval("eval outer")
+    c.Expect(err, Equals, nil)
+    context.SetMaxNesting(1)
+    _, err = context.Eval("eval outer")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("eval inner")
+    c.Expect(err, Equals, nil)
+  })
+  c.Specify("The first parameter must be a *Context.", func() {
+    c.Expect(context.AddContextFunc("bad", func(a int) int { return a }), Not(Equals), nil)
+    c.Expect(context.AddContextFunc("none", func() int { return 1 }), Not(Equals), nil)
+  })
+}
+
+func EmptyContextSpec(c gospec.Context) {
+  c.Specify("Operators on an empty Context suggest adding functions.", func() {
+    context := polish.MakeContext()
+    _, err := context.Eval("+ 1 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "unknown function '+'"), Equals, true)
+    c.Expect(strings.Contains(err.Error(), "no functions have been added"), Equals, true)
+  })
+  c.Specify("The suggestion is also made without String in the parse order.", func() {
+    context := polish.MakeContext()
+    context.SetParseOrder(polish.Integer, polish.Float)
+    _, err := context.Eval("foo 1 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "no functions have been added"), Equals, true)
+  })
+  c.Specify("Lone terms are still parsed as literals.", func() {
+    context := polish.MakeContext()
+    res, err := context.Eval("+")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "+")
+  })
+  c.Specify("There is no suggestion once functions have been added.", func() {
+    context := polish.MakeContext()
+    polish.AddBooleanContext(context)
+    context.SetParseOrder(polish.Integer, polish.Float)
+    _, err := context.Eval("+ 1 2")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "no functions have been added"), Equals, false)
+  })
+}
+
+func RemoveSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("rate", func() int { return 5 })
+  context.SetValue("limit", 10)
+  context.SetLazyValue("slow", func() interface{} { return 1 })
+  c.Specify("Setting a value with a function's name suggests RemoveFunc.", func() {
+    err := context.SetValue("rate", 6)
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "Cannot set the value 'rate' because it is currently a function, remove it with RemoveFunc first.")
+    err = context.SetLazyValue("rate", func() interface{} { return 6 })
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "Cannot set the value 'rate' because it is currently a function, remove it with RemoveFunc first.")
+  })
+  c.Specify("Adding a function with a value's name suggests RemoveValue.", func() {
+    err := context.AddFunc("limit", func() int { return 11 })
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "Cannot add the function 'limit' because it is currently a value, remove it with RemoveValue first.")
+    err = context.AddFunc("slow", func() int { return 2 })
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "Cannot add the function 'slow' because it is currently a value, remove it with RemoveValue first.")
+  })
+  c.Specify("Following the guidance works.", func() {
+    c.Assume(context.RemoveFunc("rate"), Equals, nil)
+    c.Assume(context.SetValue("rate", 6), Equals, nil)
+    res, err := context.Eval("rate")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 6)
+    c.Assume(context.RemoveValue("rate"), Equals, nil)
+    c.Assume(context.AddFunc("rate", func() int { return 7 }), Equals, nil)
+    res, err = context.Eval("rate")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 7)
+  })
+  c.Specify("Built-in functions can be removed.", func() {
+    c.Assume(context.RemoveFunc("+"), Equals, nil)
+    c.Assume(context.AddFunc("+", func(a, b, c int) int { return a + b + c }), Equals, nil)
+    res, err := context.Eval("+ 1 2 3")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 6)
+    c.Expect(context.IsBuiltin("+"), Equals, false)
+  })
+  c.Specify("Removing something that does not exist is an error.", func() {
+    c.Expect(context.RemoveFunc("nothing"), Not(Equals), nil)
+    c.Expect(context.RemoveFunc("limit"), Not(Equals), nil)
+    c.Expect(context.RemoveValue("nothing"), Not(Equals), nil)
+    c.Expect(context.RemoveValue("-"), Not(Equals), nil)
+  })
+  c.Specify("Overridden names must be restored first.", func() {
+    restore := context.WithOverride("shadow", 1)
+    err := context.AddFunc("shadow", func() int { return 2 })
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "Cannot add the function 'shadow' because it is currently overridden by WithOverride, restore it first.")
+    restore()
+    c.Expect(context.AddFunc("shadow", func() int { return 2 }), Equals, nil)
+  })
+}
+
+func DisableSpec(c gospec.Context) {
+  c.Specify("Disabled operators are reported as disabled.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    context.Disable("^", "ln")
+    _, err := context.Eval("^ 2.0 10.0")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "'^' is disabled in this Context.")
+    _, err = context.Eval("+ 1.0 ln 2.0")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "'ln' is disabled in this Context.")
+    c.Expect(context.Check("^ 2.0 10.0"), Not(Equals), nil)
+    res, err := context.Eval("* 2.0 3.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 6.0)
+  })
+  c.Specify("Disabled names cannot be reached through fold or map.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.Disable("+")
+    _, err := context.Eval("fold + 0 [ 1 2 3 ]")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(err.Error(), Equals, "'+' is disabled in this Context.")
+  })
+  c.Specify("Adding a function enables its name again.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.Disable("^", "unknown")
+    _, err := context.Eval("unknown")
+    c.Expect(err, Not(Equals), nil)
+    c.Assume(context.AddFunc("^", func(a, b int) int { return a * b }), Equals, nil)
+    res, err := context.Eval("^ 3 4")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 12)
+  })
+  c.Specify("Values with a disabled name are still used.", func() {
+    context := polish.MakeContext()
+    context.Disable("limit")
+    context.SetValue("limit", 3)
+    res, err := context.Eval("limit")
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 3)
+    c.Expect(context.Check("limit"), Equals, nil)
+  })
+}
+
+func IsBuiltinSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddStatsContext(context)
+  context.AddFunc("double", func(a float64) float64 { return 2 * a })
+  context.AddPureFunc("half", func(a float64) float64 { return a / 2 })
+  c.Specify("Functions from the built-in contexts are built-in.", func() {
+    c.Expect(context.IsBuiltin("+"), Equals, true)
+    c.Expect(context.IsBuiltin("=="), Equals, true)
+    c.Expect(context.IsBuiltin("mean"), Equals, true)
+  })
+  c.Specify("Functions added by the user are not.", func() {
+    c.Expect(context.IsBuiltin("double"), Equals, false)
+    c.Expect(context.IsBuiltin("half"), Equals, false)
+    c.Expect(context.AddFunc("+", func(a, b int) int { return a + b }), Not(Equals), nil)
+    c.Expect(context.IsBuiltin("+"), Equals, true)
+  })
+  c.Specify("Values and unknown names are not.", func() {
+    c.Expect(context.IsBuiltin("pi"), Equals, false)
+    c.Expect(context.IsBuiltin("nothing"), Equals, false)
+    c.Expect(context.IsBuiltin("map"), Equals, false)
+  })
+}
+
+func NamesSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddBooleanContext(context)
+  context.AddFunc("zeta", func() int { return 0 })
+  context.AddFunc("alpha", func() int { return 0 })
+  context.SetValue("b", 1)
+  context.SetLazyValue("a", func() interface{} { return 2 })
+  c.Specify("Function names are sorted.", func() {
+    c.Expect(context.FuncNames(), ContainsInOrder, []string{"!", "&&", "->", "<->", "^^", "alpha", "nand", "nor", "zeta", "||"})
+    c.Expect(len(context.FuncNames()), Equals, 10)
+  })
+  c.Specify("Value names are sorted and include lazy and overridden values.", func() {
+    restore := context.WithOverride("c", 3)
+    c.Expect(context.ValueNames(), ContainsInOrder, []string{"a", "b", "c", "false", "true"})
+    c.Expect(len(context.ValueNames()), Equals, 5)
+    restore()
+    c.Expect(len(context.ValueNames()), Equals, 4)
+  })
+}
+
+func RoundToSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  expectFloat := func(expression string, expected float64) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Float(), Equals, expected)
+  }
+  c.Specify("Values round to multiples of a step.", func() {
+    expectFloat("roundto 7.0 5.0", 5.0)
+    expectFloat("roundto 8.0 5.0", 10.0)
+    expectFloat("roundto 7.5 5.0", 10.0)
+    expectFloat("roundto -7.0 5.0", -5.0)
+    expectFloat("roundto -7.5 5.0", -10.0)
+    expectFloat("floorto 7.0 5.0", 5.0)
+    expectFloat("floorto -7.0 5.0", -10.0)
+    expectFloat("floorto 10.0 5.0", 10.0)
+    expectFloat("ceilto 7.0 5.0", 10.0)
+    expectFloat("ceilto -7.0 5.0", -5.0)
+    expectFloat("ceilto 7.0 -5.0", 10.0)
+    expectFloat("floorto 1.3 0.25", 1.25)
+  })
+  c.Specify("A zero step is an error.", func() {
+    for _, expression := range []string{"roundto 1.0 0.0", "floorto 1.0 0.0", "ceilto 1.0 0.0"} {
+      _, err := context.Eval(expression)
+      c.Expect(err, Not(Equals), nil)
+    }
+  })
+}
+
+func ClampPctSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  expectFloat := func(expression string, expected float64) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Float(), Equals, expected)
+  }
+  c.Specify("clamp bounds a value to a range.", func() {
+    expectFloat("clamp 0.5 0.0 1.0", 0.5)
+    expectFloat("clamp -2.0 0.0 1.0", 0.0)
+    expectFloat("clamp 7.0 0.0 1.0", 1.0)
+    expectFloat("clamp 3.0 2.0 2.0", 2.0)
+  })
+  c.Specify("clamp is an error if the range is backwards.", func() {
+    _, err := context.Eval("clamp 0.5 1.0 0.0")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("pct gives a percentage.", func() {
+    expectFloat("pct 1.0 4.0", 25.0)
+    expectFloat("pct 3.0 2.0", 150.0)
+  })
+}
+
+type describer interface {
+  Describe() string
+}
+
+type named string
+
+func (n named) Describe() string { return "named " + string(n) }
+
+func InterfaceParamSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("show", func(x interface{}) string { return fmt.Sprintf("%T:%v", x, x) })
+  context.AddFunc("describe", func(d describer) string { return d.Describe() })
+  c.Specify("Any value can be passed as an interface{}.", func() {
+    for expression, expected := range map[string]string{
+      "show 3":       "int:3",
+      "show 2.5":     "float64:2.5",
+      "show < 1 2":   "bool:true",
+      "show [1 2]":   "[]int:[1 2]",
+      "show + 1 2":   "int:3",
+    } {
+      res, err := context.Eval(expression)
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].String(), Equals, expected)
+    }
+  })
+  c.Specify("nil values are passed as nil interfaces.", func() {
+    context.SetValue("nothing", nil)
+    res, err := context.Eval("show nothing")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "<nil>:<nil>")
+    _, err = context.Eval("+ 1 nothing")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Non-empty interfaces only accept values that implement them.", func() {
+    context.SetValue("bob", named("bob"))
+    res, err := context.Eval("describe bob")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].String(), Equals, "named bob")
+    _, err = context.Eval("describe 3")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "does not implement"), Equals, true)
+  })
+  c.Specify("Mismatched types are reported clearly.", func() {
+    _, err := context.Eval("+ 1 2.5")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "Argument 2 of '+' is a float64, which cannot be used as an int."), Equals, true)
+  })
+}
+
+func AddFuncNamesSpec(c gospec.Context) {
+  c.Specify("A function can be added under several names.", func() {
+    context := polish.MakeContext()
+    c.Assume(context.AddFuncNames([]string{"!", "not"}, func(a bool) bool { return !a }), Equals, nil)
+    context.SetValue("t", true)
+    for _, expression := range []string{"! t", "not t"} {
+      res, err := context.Eval(expression)
+      c.Assume(err, Equals, nil)
+      c.Expect(res[0].Bool(), Equals, false)
+    }
+  })
+  c.Specify("No names are added if any of them conflict.", func() {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.SetValue("zero", 0)
+    err := context.AddFuncNames([]string{"neg", "zero", "negate", "-", "neg"}, func(a int) int { return -a })
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(strings.Contains(err.Error(), "'-', 'neg', 'zero'"), Equals, true)
+    _, ok := context.FuncDoc("neg")
+    c.Expect(ok, Equals, false)
+    _, ok = context.FuncDoc("negate")
+    c.Expect(ok, Equals, false)
+  })
+  c.Specify("Non-functions are rejected.", func() {
+    context := polish.MakeContext()
+    c.Expect(context.AddFuncNames([]string{"a", "b"}, 3), Not(Equals), nil)
+    c.Expect(len(context.FuncNames()), Equals, 0)
+  })
+}
@@ -0,0 +1,31 @@
+package polish
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strings"
+)
+
+// Reads expressions from in, one per line, and writes the result of each to
+// out as rendered by EvalToString.  Blank lines are skipped, and errors are
+// written to out without stopping the loop.  Returns when in is exhausted, or
+// when reading from in fails, in which case that error is also written to out.
+func REPL(c *Context, in io.Reader, out io.Writer) {
+  scanner := bufio.NewScanner(in)
+  for scanner.Scan() {
+    line := scanner.Text()
+    if strings.TrimSpace(line) == "" {
+      continue
+    }
+    s, err := c.EvalToString(line)
+    if err != nil {
+      fmt.Fprintf(out, "error: %v\n", err)
+      continue
+    }
+    fmt.Fprintln(out, s)
+  }
+  if err := scanner.Err(); err != nil {
+    fmt.Fprintf(out, "error: %v\n", err)
+  }
+}
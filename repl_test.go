@@ -0,0 +1,34 @@
+package polish_test
+
+import (
+  "bytes"
+  "errors"
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "io"
+  "strings"
+  "testing/iotest"
+)
+
+func REPLSpec(c gospec.Context) {
+  c.Specify("The REPL prints results and errors for each line.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    var out bytes.Buffer
+    polish.REPL(context, strings.NewReader("+ 1.0 2.5\n\n   \n+ 1.0\n* 2.0 3.0"), &out)
+    lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+    c.Assume(len(lines), Equals, 3)
+    c.Expect(lines[0], Equals, "3.5")
+    c.Expect(strings.HasPrefix(lines[1], "error: "), Equals, true)
+    c.Expect(lines[2], Equals, "6")
+  })
+  c.Specify("The REPL reports errors reading its input.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    var out bytes.Buffer
+    in := io.MultiReader(strings.NewReader("+ 1.0 2.5\n"), iotest.ErrReader(errors.New("broken pipe")))
+    polish.REPL(context, in, &out)
+    c.Expect(out.String(), Equals, "3.5\nerror: broken pipe\n")
+  })
+}
@@ -0,0 +1,140 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+)
+
+// A Result wraps a single value produced by Eval1.  Its accessors return an
+// error rather than panicking when the value is not of the requested kind.
+type Result struct {
+  v reflect.Value
+}
+
+// Returns the underlying reflect.Value.
+func (r Result) Value() reflect.Value {
+  return r.v
+}
+
+// Returns the kind of the underlying value.
+func (r Result) Kind() reflect.Kind {
+  return r.v.Kind()
+}
+
+func (r Result) mismatch(want string) error {
+  return &Error{fmt.Sprintf("Result is a %v, not a %s.", typeOf(r.v), want), nil, nil}
+}
+
+// Returns the value as a float64 if it is a float32 or float64.
+func (r Result) Float() (float64, error) {
+  switch r.v.Kind() {
+  case reflect.Float32, reflect.Float64:
+    return r.v.Float(), nil
+  }
+  return 0, r.mismatch("float")
+}
+
+// Returns the value as an int64 if it is any signed integer kind.
+func (r Result) Int() (int64, error) {
+  switch r.v.Kind() {
+  case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+    return r.v.Int(), nil
+  }
+  return 0, r.mismatch("signed integer")
+}
+
+// Returns the value as a bool if it is a bool.
+func (r Result) Bool() (bool, error) {
+  if r.v.Kind() == reflect.Bool {
+    return r.v.Bool(), nil
+  }
+  return false, r.mismatch("bool")
+}
+
+// Returns the value as a string if it is a string.
+func (r Result) String() (string, error) {
+  if r.v.Kind() == reflect.String {
+    return r.v.String(), nil
+  }
+  return "", r.mismatch("string")
+}
+
+// Evaluates an expression that must produce exactly one value and returns
+// that value as a Result.
+func (c *Context) Eval1(expression string) (Result, error) {
+  vs, err := c.Eval(expression)
+  if err != nil {
+    return Result{}, err
+  }
+  if len(vs) != 1 {
+    return Result{}, &Error{fmt.Sprintf("Expected (%s) to produce 1 value, got %d.", expression, len(vs)), nil, nil}
+  }
+  return Result{vs[0]}, nil
+}
+
+// Evaluates an expression that must produce exactly one value and returns it
+// as a T, so that callers do not need to use reflect, as in
+//   area, err := polish.Eval[float64](c, "* pi ^ r 2.0")
+// The value is converted to T by these rules, and anything else is an Error:
+//   - A value that is assignable to T is used as it is, which covers T being
+//     exactly the type of the value, and T being an interface it implements,
+//     such as any.
+//   - A signed integer converts to any signed integer type, and an unsigned
+//     integer to any unsigned integer type, as long as it fits.
+//   - A float converts to any float type, rounding if T is smaller.
+//   - A bool or string converts to any type whose underlying type is bool or
+//     string.
+//   - A nil value, such as one made by SetValue(name, nil), is the zero value
+//     of T if T is an interface, pointer, slice, map, func or chan type.
+// Integers never convert to floats or the other way around, since that is
+// more likely to be a mistake in the expression than a conversion the caller
+// wanted.
+func Eval[T any](c *Context, expression string) (T, error) {
+  var t T
+  r, err := c.Eval1(expression)
+  if err != nil {
+    return t, err
+  }
+  v := r.Value()
+  target := reflect.TypeOf(&t).Elem()
+  if !v.IsValid() {
+    switch target.Kind() {
+    case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map, reflect.Func, reflect.Chan:
+      return t, nil
+    }
+    return t, &Error{fmt.Sprintf("Cannot convert the nil result of (%s) to a %v.", expression, target), nil, nil}
+  }
+  if v.Type().AssignableTo(target) {
+    reflect.ValueOf(&t).Elem().Set(v)
+    return t, nil
+  }
+  ok := false
+  switch kindClass(v.Kind()) {
+  case reflect.Int:
+    ok = kindClass(target.Kind()) == reflect.Int && !reflect.Zero(target).OverflowInt(v.Int())
+  case reflect.Uint:
+    ok = kindClass(target.Kind()) == reflect.Uint && !reflect.Zero(target).OverflowUint(v.Uint())
+  case reflect.Float64, reflect.Bool, reflect.String:
+    ok = kindClass(target.Kind()) == kindClass(v.Kind())
+  }
+  if !ok {
+    return t, &Error{fmt.Sprintf("Cannot convert the %v result of (%s) to a %v.", v.Type(), expression, target), nil, nil}
+  }
+  reflect.ValueOf(&t).Elem().Set(v.Convert(target))
+  return t, nil
+}
+
+// Groups kinds that Eval converts between, returning reflect.Int for every
+// signed integer kind, reflect.Uint for every unsigned one, and
+// reflect.Float64 for both float kinds.  Other kinds are returned unchanged.
+func kindClass(k reflect.Kind) reflect.Kind {
+  switch k {
+  case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+    return reflect.Int
+  case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+    return reflect.Uint
+  case reflect.Float32, reflect.Float64:
+    return reflect.Float64
+  }
+  return k
+}
@@ -0,0 +1,142 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "math"
+  "reflect"
+)
+
+func ResultSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddBooleanContext(context)
+  context.AddFunc("two", func() (int, int) { return 1, 2 })
+  context.AddFunc("count", func(a float64) int { return int(a) })
+  context.AddFunc("name", func() string { return "polish" })
+  c.Specify("Accessors return values of the matching kind.", func() {
+    r, err := context.Eval1("* 2.0 3.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(r.Kind(), Equals, reflect.Float64)
+    f, err := r.Float()
+    c.Expect(err, Equals, nil)
+    c.Expect(f, Equals, 6.0)
+
+    r, err = context.Eval1("count 3.5")
+    c.Assume(err, Equals, nil)
+    i, err := r.Int()
+    c.Expect(err, Equals, nil)
+    c.Expect(i, Equals, int64(3))
+
+    r, err = context.Eval1("< 1.0 2.0")
+    c.Assume(err, Equals, nil)
+    b, err := r.Bool()
+    c.Expect(err, Equals, nil)
+    c.Expect(b, Equals, true)
+
+    r, err = context.Eval1("name")
+    c.Assume(err, Equals, nil)
+    s, err := r.String()
+    c.Expect(err, Equals, nil)
+    c.Expect(s, Equals, "polish")
+  })
+  c.Specify("Accessors return errors on a kind mismatch.", func() {
+    r, err := context.Eval1("< 1.0 2.0")
+    c.Assume(err, Equals, nil)
+    _, err = r.Float()
+    c.Expect(err, Not(Equals), nil)
+    _, err = r.Int()
+    c.Expect(err, Not(Equals), nil)
+    _, err = r.String()
+    c.Expect(err, Not(Equals), nil)
+
+    r, err = context.Eval1("1.5")
+    c.Assume(err, Equals, nil)
+    _, err = r.Bool()
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Accessors return errors for nil results.", func() {
+    context.SetValue("missing", nil)
+    r, err := context.Eval1("missing")
+    c.Assume(err, Equals, nil)
+    for _, r := range []polish.Result{r, polish.Result{}} {
+      c.Expect(r.Kind(), Equals, reflect.Invalid)
+      _, err = r.Float()
+      c.Expect(err, Not(Equals), nil)
+      _, err = r.Int()
+      c.Expect(err, Not(Equals), nil)
+      _, err = r.Bool()
+      c.Expect(err, Not(Equals), nil)
+      _, err = r.String()
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, "Result is a <nil>, not a string.")
+    }
+  })
+  c.Specify("Eval1 requires exactly one value.", func() {
+    _, err := context.Eval1("two")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+type celsius float64
+
+func GenericEvalSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  context.AddFunc("big", func() int64 { return 1 << 40 })
+  context.AddFunc("small", func() int { return -3 })
+  context.AddFunc("name", func() string { return "polish" })
+  context.SetValue("nothing", nil)
+  c.Specify("Results are returned as the requested type.", func() {
+    f, err := polish.Eval[float64](context, "* 2.0 pi")
+    c.Assume(err, Equals, nil)
+    c.Expect(f, Equals, 2*math.Pi)
+    b, err := polish.Eval[bool](context, "< 1.0 2.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(b, Equals, true)
+    s, err := polish.Eval[string](context, "name")
+    c.Assume(err, Equals, nil)
+    c.Expect(s, Equals, "polish")
+    a, err := polish.Eval[interface{}](context, "small")
+    c.Assume(err, Equals, nil)
+    c.Expect(a, Equals, -3)
+  })
+  c.Specify("Values convert within their kind.", func() {
+    f, err := polish.Eval[float32](context, "/ 1.0 4.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(f, Equals, float32(0.25))
+    t, err := polish.Eval[celsius](context, "+ 20.0 1.5")
+    c.Assume(err, Equals, nil)
+    c.Expect(t, Equals, celsius(21.5))
+    i, err := polish.Eval[int8](context, "small")
+    c.Assume(err, Equals, nil)
+    c.Expect(i, Equals, int8(-3))
+    n, err := polish.Eval[int64](context, "big")
+    c.Assume(err, Equals, nil)
+    c.Expect(n, Equals, int64(1<<40))
+  })
+  c.Specify("Mismatches and overflows are errors.", func() {
+    _, err := polish.Eval[int](context, "pi")
+    c.Expect(err, Not(Equals), nil)
+    _, err = polish.Eval[float64](context, "small")
+    c.Expect(err, Not(Equals), nil)
+    _, err = polish.Eval[int32](context, "big")
+    c.Expect(err, Not(Equals), nil)
+    _, err = polish.Eval[uint](context, "small")
+    c.Expect(err, Not(Equals), nil)
+    _, err = polish.Eval[string](context, "< 1.0 2.0")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("nil converts only to types that can be nil.", func() {
+    p, err := polish.Eval[*int](context, "nothing")
+    c.Assume(err, Equals, nil)
+    c.Expect(p == nil, Equals, true)
+    _, err = polish.Eval[int](context, "nothing")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Evaluation errors are returned.", func() {
+    _, err := polish.Eval[float64](context, "+ 1.0")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
@@ -0,0 +1,122 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+  "strings"
+)
+
+// A StatementError is the error from a single statement of a script.
+type StatementError struct {
+  // Index of the statement among the non-empty statements of the script.
+  Index int
+
+  // Byte offset of the first term of the statement in the script.
+  Offset int
+
+  Err error
+}
+
+func (e *StatementError) Error() string {
+  return fmt.Sprintf("Statement %d at offset %d: %v", e.Index, e.Offset, e.Err)
+}
+
+func (e *StatementError) Unwrap() error {
+  return e.Err
+}
+
+// ScriptErrors holds the errors from every statement that failed when
+// EvalScript is collecting errors.
+type ScriptErrors []*StatementError
+
+func (e ScriptErrors) Error() string {
+  msgs := make([]string, len(e))
+  for i, err := range e {
+    msgs[i] = err.Error()
+  }
+  return strings.Join(msgs, "\n")
+}
+
+func (e ScriptErrors) Unwrap() []error {
+  errs := make([]error, len(e))
+  for i, err := range e {
+    errs[i] = err
+  }
+  return errs
+}
+
+// Splits a script on semicolons that are not inside of quoted terms, skipping
+// statements that are empty.  Returns each statement and the offset of its
+// first term.
+func (c *Context) splitStatements(script string) (stmts []string, offsets []int) {
+  start := 0
+  var quote rune
+  escaped := false
+  token := false
+  add := func(end int) {
+    stmt := strings.TrimLeftFunc(script[start:end], c.isDelim)
+    if stmt != "" {
+      stmts = append(stmts, stmt)
+      offsets = append(offsets, end-len(stmt))
+    }
+  }
+  for i, r := range script {
+    switch {
+    case quote != 0:
+      switch {
+      case escaped:
+        escaped = false
+      case r == '\\':
+        escaped = true
+      case r == quote:
+        quote = 0
+      }
+    case r == ';':
+      add(i)
+      start = i + 1
+      token = false
+    case c.isDelim(r):
+      token = false
+    case !token:
+      token = true
+      if r == '\'' || r == '"' {
+        quote = r
+      }
+    }
+  }
+  add(len(script))
+  return
+}
+
+// Evaluates each statement of a script, where statements are expressions
+// separated by semicolons, and returns the results of each of them.  By default
+// evaluation stops at the first statement that fails, and the results of the
+// statements before it are returned along with a *StatementError.  See
+// SetCollectErrors for evaluating every statement regardless.
+func (c *Context) EvalScript(script string) ([][]reflect.Value, error) {
+  stmts, offsets := c.splitStatements(script)
+  var results [][]reflect.Value
+  var errs ScriptErrors
+  for i, stmt := range stmts {
+    vs, err := c.Eval(stmt)
+    if err != nil {
+      serr := &StatementError{Index: i, Offset: offsets[i], Err: err}
+      if !c.collect_errors {
+        return results, serr
+      }
+      errs = append(errs, serr)
+    }
+    results = append(results, vs)
+  }
+  if len(errs) > 0 {
+    return results, errs
+  }
+  return results, nil
+}
+
+// When set, EvalScript evaluates every statement even if some of them fail.
+// The results of failed statements are nil, and the error returned is a
+// ScriptErrors holding a *StatementError for each of them.
+func (c *Context) SetCollectErrors(collect bool) {
+  c.collect_errors = collect
+}
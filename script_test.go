@@ -0,0 +1,55 @@
+package polish_test
+
+import (
+  "errors"
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+)
+
+func EvalScriptSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddFunc("isspace", func(r rune) bool { return r == ' ' })
+  c.Specify("Statements are evaluated in order.", func() {
+    res, err := context.EvalScript("+ 1 2; * 3 4 ;; isspace ' ';\n")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 3)
+    c.Expect(int(res[0][0].Int()), Equals, 3)
+    c.Expect(int(res[1][0].Int()), Equals, 12)
+    c.Expect(res[2][0].Bool(), Equals, true)
+  })
+  c.Specify("Semicolons inside quotes do not split statements.", func() {
+    context.AddFunc("issemi", func(r rune) bool { return r == ';' })
+    res, err := context.EvalScript("issemi ';'; issemi 'a'")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 2)
+    c.Expect(res[0][0].Bool(), Equals, true)
+    c.Expect(res[1][0].Bool(), Equals, false)
+  })
+  c.Specify("The first failure stops the script by default.", func() {
+    res, err := context.EvalScript("+ 1 2; + 1; * 2 3; -")
+    c.Assume(err, Not(Equals), nil)
+    c.Expect(len(res), Equals, 1)
+    var serr *polish.StatementError
+    c.Assume(errors.As(err, &serr), Equals, true)
+    c.Expect(serr.Index, Equals, 1)
+    c.Expect(serr.Offset, Equals, 7)
+  })
+  c.Specify("Errors can be collected from every statement.", func() {
+    context.SetCollectErrors(true)
+    res, err := context.EvalScript("+ 1 2; + 1; * 2 3; -")
+    c.Assume(err, Not(Equals), nil)
+    c.Assume(len(res), Equals, 4)
+    c.Expect(int(res[2][0].Int()), Equals, 6)
+    c.Expect(len(res[1]), Equals, 0)
+    errs, ok := err.(polish.ScriptErrors)
+    c.Assume(ok, Equals, true)
+    c.Assume(len(errs), Equals, 2)
+    c.Expect(errs[0].Index, Equals, 1)
+    c.Expect(errs[0].Offset, Equals, 7)
+    c.Expect(errs[1].Index, Equals, 3)
+    c.Expect(errs[1].Offset, Equals, 19)
+    context.SetCollectErrors(false)
+  })
+}
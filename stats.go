@@ -0,0 +1,66 @@
+package polish
+
+import (
+  "fmt"
+  "math"
+)
+
+func checkNotEmpty(name string, v []float64) {
+  if len(v) == 0 {
+    panic(fmt.Sprintf("Cannot take the %s of an empty list.", name))
+  }
+}
+
+func sSum(v []float64) float64 {
+  var sum float64
+  for _, x := range v {
+    sum += x
+  }
+  return sum
+}
+
+func sMean(v []float64) float64 {
+  checkNotEmpty("mean", v)
+  return sSum(v) / float64(len(v))
+}
+
+func sStddev(v []float64) float64 {
+  checkNotEmpty("stddev", v)
+  mean := sMean(v)
+  var sum float64
+  for _, x := range v {
+    sum += (x - mean) * (x - mean)
+  }
+  return math.Sqrt(sum / float64(len(v)))
+}
+
+func sMin(v []float64) float64 {
+  checkNotEmpty("min", v)
+  min := v[0]
+  for _, x := range v[1:] {
+    min = math.Min(min, x)
+  }
+  return min
+}
+
+func sMax(v []float64) float64 {
+  checkNotEmpty("max", v)
+  max := v[0]
+  for _, x := range v[1:] {
+    max = math.Max(max, x)
+  }
+  return max
+}
+
+// Adds summary statistics over []float64 lists to the Context.  The sum of an
+// empty list is 0, every other function is an error on an empty list rather
+// than returning NaN.  If any element is NaN then so is the result.
+//   Functions: sum mean min max
+//              stddev (population standard deviation)
+func AddStatsContext(c *Context) {
+  c.addBuiltin("sum", sSum, "Sum of a list, 0 if it is empty.")
+  c.addBuiltin("mean", sMean, "Arithmetic mean of a non-empty list.")
+  c.addBuiltin("stddev", sStddev, "Population standard deviation of a non-empty list.")
+  c.addBuiltin("min", sMin, "Smallest element of a non-empty list.")
+  c.addBuiltin("max", sMax, "Largest element of a non-empty list.")
+}
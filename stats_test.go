@@ -0,0 +1,35 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+)
+
+func StatsContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddStatsContext(context)
+  context.SetValue("data", []float64{2, 4, 4, 4, 5, 5, 7, 9})
+  context.SetValue("empty", []float64{})
+  expectFloat := func(expression string, expected float64) {
+    res, err := context.Eval(expression)
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, expected)
+  }
+  c.Specify("Statistics are computed over lists.", func() {
+    expectFloat("sum data", 40)
+    expectFloat("mean data", 5)
+    expectFloat("stddev data", 2)
+    expectFloat("min data", 2)
+    expectFloat("max data", 9)
+    expectFloat("max [1.5 -2.0 0.5]", 1.5)
+  })
+  c.Specify("Empty lists are handled.", func() {
+    expectFloat("sum empty", 0)
+    for _, name := range []string{"mean", "stddev", "min", "max"} {
+      _, err := context.Eval(name + " empty")
+      c.Expect(err, Not(Equals), nil)
+    }
+  })
+}
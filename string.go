@@ -0,0 +1,27 @@
+package polish
+
+import (
+  "strings"
+)
+
+// Adds functions for working with strings to the Context.  Any term that is
+// not a function, value or number is a string literal, so "startswith foo f"
+// is true.
+//   Functions that return a bool: contains startswith endswith
+//   Functions that return an int: len
+//   Functions that return a string: concat join
+//   Functions that return a []string: split
+// contains s sub, startswith s prefix and endswith s suffix test s against
+// their second argument.  len s counts bytes, as len does in Go.  split s sep
+// is the list of substrings of s between each sep, which can be passed to map
+// and fold or to join list sep, which reverses it.  An empty sep splits s into
+// its UTF-8 sequences.
+func AddStringContext(c *Context) {
+  c.addBuiltin("concat", func(a, b string) string { return a + b }, "a followed by b.")
+  c.addBuiltin("len", func(s string) int { return len(s) }, "Length of s in bytes.")
+  c.addBuiltin("contains", strings.Contains, "True if sub is within s.")
+  c.addBuiltin("startswith", strings.HasPrefix, "True if s begins with prefix.")
+  c.addBuiltin("endswith", strings.HasSuffix, "True if s ends with suffix.")
+  c.addBuiltin("split", strings.Split, "List of the substrings of s separated by sep.")
+  c.addBuiltin("join", strings.Join, "Elements of list separated by sep.")
+}
@@ -0,0 +1,52 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func StringContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddStringContext(context)
+  eval1 := func(expression string) reflect.Value {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    return res[0]
+  }
+  c.Specify("Predicates return bools.", func() {
+    c.Expect(eval1("startswith foobar foo").Bool(), Equals, true)
+    c.Expect(eval1("startswith foobar bar").Bool(), Equals, false)
+    c.Expect(eval1("endswith foobar bar").Bool(), Equals, true)
+    c.Expect(eval1("endswith foobar foo").Bool(), Equals, false)
+    c.Expect(eval1("contains foobar oba").Bool(), Equals, true)
+    c.Expect(eval1("contains foobar abo").Bool(), Equals, false)
+  })
+  c.Specify("concat and len.", func() {
+    c.Expect(eval1("concat foo bar").String(), Equals, "foobar")
+    c.Expect(int(eval1("len concat foo bar").Int()), Equals, 6)
+  })
+  c.Specify("split returns a []string.", func() {
+    v := eval1("split a,bb,ccc ,")
+    c.Assume(v.Type(), Equals, reflect.TypeOf([]string{}))
+    c.Expect(v.Len(), Equals, 3)
+    c.Expect(v.Index(2).String(), Equals, "ccc")
+    c.Expect(eval1("join split a,bb,ccc , -").String(), Equals, "a-bb-ccc")
+  })
+  c.Specify("split feeds map and fold.", func() {
+    v := eval1("map len split a,bb,ccc ,")
+    c.Assume(v.Type(), Equals, reflect.TypeOf([]int{}))
+    c.Expect(int(v.Index(1).Int()), Equals, 2)
+    c.Expect(eval1("fold concat > split a,b,c ,").String(), Equals, ">abc")
+  })
+  c.Specify("Return types are known statically.", func() {
+    typ, err := context.TypeCheck("split a,b ,")
+    c.Assume(err, Equals, nil)
+    c.Expect(typ, Equals, reflect.TypeOf([]string{}))
+    typ, err = context.TypeCheck("startswith a b")
+    c.Assume(err, Equals, nil)
+    c.Expect(typ, Equals, reflect.TypeOf(true))
+  })
+}
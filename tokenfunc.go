@@ -0,0 +1,89 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+)
+
+// A TokenCursor gives a function added with AddTokenFunc the terms that come
+// after it in an expression, so that it can read them with its own syntax
+// rather than having them evaluated as arguments.
+type TokenCursor struct {
+  terms []string
+}
+
+// Returns the number of terms left in the expression.
+func (tc TokenCursor) Len() int {
+  return len(tc.terms)
+}
+
+// Returns the i-th term after the function, counting from 0, ok is false if
+// the expression ends before it.
+func (tc TokenCursor) Peek(i int) (term string, ok bool) {
+  if i < 0 || i >= len(tc.terms) {
+    return "", false
+  }
+  return tc.terms[i], true
+}
+
+// Returns a copy of all of the terms left in the expression.
+func (tc TokenCursor) Terms() []string {
+  return append([]string(nil), tc.terms...)
+}
+
+// A TokenFunc reads the terms after it from cursor and returns the values it
+// produces along with the number of terms it consumed, see AddTokenFunc.
+type TokenFunc func(cursor TokenCursor) (results []reflect.Value, consumed int, err error)
+
+// Adds a function that reads the terms after it itself, instead of having its
+// arguments evaluated, as an escape hatch for syntax that polish does not
+// have, such as an inline table of values.  When name is reached during
+// evaluation f is given a TokenCursor over every remaining term of the whole
+// expression, exactly as tokenized, so it sees brackets as terms of their own,
+// does not see comments, and may see terms that belong to enclosing
+// functions; it must decide for itself where its syntax ends.  f returns:
+//   - results, the values it produces, which are used exactly like the
+//     return values of a function added with AddFunc,
+//   - consumed, how many terms from the start of the cursor it used, which
+//     must be between 0 and cursor.Len(); evaluation continues after them,
+//   - err, which fails the evaluation and is wrapped by the Error returned.
+// A panic in f is reported as an Error too.  Parse, Check, IsComplete,
+// TypeCheck and Compile call f as well, to learn how many terms it consumes
+// and how many values it produces, so f should not have side effects and
+// should consume the same terms every time it is given the same ones.  Parse
+// makes each consumed term a child Node with no children of its own.  Token
+// functions are never pure.
+func (c *Context) AddTokenFunc(name string, f TokenFunc) error {
+  if f == nil {
+    return &Error{fmt.Sprintf("Tried to add a nil TokenFunc as the function '%s'.", name), nil, nil}
+  }
+  if err := c.addFunc(name, f, false, ""); err != nil {
+    return err
+  }
+  fn := c.funcs[name]
+  fn.token = true
+  fn.num = 0
+  c.funcs[name] = fn
+  return nil
+}
+
+// Calls the token function f, whose name is term, with a cursor over terms and
+// checks what it returns.
+func callTokenFunc(term string, f function, terms []string) (vs []reflect.Value, consumed int, err error) {
+  defer func() {
+    if r := recover(); r != nil {
+      vs, consumed, err = nil, 0, &Error{fmt.Sprintf("Token function '%s' panicked: %v.", term, r), nil, nil}
+      if e, ok := r.(error); ok {
+        err.(*Error).Err = e
+      }
+    }
+  }()
+  vs, consumed, err = f.f.Interface().(TokenFunc)(TokenCursor{terms})
+  if err != nil {
+    return nil, 0, &Error{fmt.Sprintf("Token function '%s' failed: %v.", term, err), nil, err}
+  }
+  if consumed < 0 || consumed > len(terms) {
+    return nil, 0, &Error{fmt.Sprintf("Token function '%s' consumed %d term(s), but only %d were left.", term, consumed, len(terms)), nil, nil}
+  }
+  return vs, consumed, nil
+}
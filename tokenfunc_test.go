@@ -0,0 +1,112 @@
+package polish_test
+
+import (
+  "errors"
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+  "strconv"
+)
+
+// Reads integers up to a closing "end" and produces their sum, so
+// "sum 1 2 3 end" is 6.
+func sumTokens(cursor polish.TokenCursor) ([]reflect.Value, int, error) {
+  total := 0
+  for i := 0; ; i++ {
+    term, ok := cursor.Peek(i)
+    if !ok {
+      return nil, 0, errors.New("missing end")
+    }
+    if term == "end" {
+      return []reflect.Value{reflect.ValueOf(total)}, i + 1, nil
+    }
+    n, err := strconv.Atoi(term)
+    if err != nil {
+      return nil, 0, err
+    }
+    total += n
+  }
+}
+
+func TokenFuncSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddTokenFunc("sum", sumTokens)
+    context.AddTokenFunc("rest", func(cursor polish.TokenCursor) ([]reflect.Value, int, error) {
+      return []reflect.Value{reflect.ValueOf(cursor.Len())}, 0, nil
+    })
+    context.AddTokenFunc("greedy", func(cursor polish.TokenCursor) ([]reflect.Value, int, error) {
+      return nil, cursor.Len() + 1, nil
+    })
+    context.SetEngine(engine)
+    expectInt := func(expression string, expected int) {
+      res, err := context.Eval(expression)
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(int(res[0].Int()), Equals, expected)
+    }
+    c.Specify("Token functions read their own terms.", func() {
+      expectInt("sum 1 2 3 end", 6)
+      expectInt("* 2 sum 1 2 3 end", 12)
+      expectInt("+ sum 1 2 end sum end", 3)
+      expectInt("- sum 10 end 1", 9)
+    })
+    c.Specify("Token functions see every remaining term.", func() {
+      expectInt("+ rest 5", 6)
+      expectInt("rest", 0)
+    })
+    c.Specify("Errors are reported.", func() {
+      _, err := context.Eval("sum 1 2")
+      c.Assume(err, Not(Equals), nil)
+      c.Expect(err.Error(), Equals, "Token function 'sum' failed: missing end.")
+      _, err = context.Eval("sum 1 x end")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("greedy 1 2")
+      c.Expect(err, Not(Equals), nil)
+    })
+  }
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  context.AddTokenFunc("sum", sumTokens)
+  c.Specify("Parse keeps the consumed terms as children.", func() {
+    n, err := context.Parse("* 2 sum 1 2 end")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(n.Children), Equals, 2)
+    c.Expect(len(n.Children[1].Children), Equals, 3)
+    c.Expect(n.String(), Equals, "* 2 sum 1 2 end")
+    c.Expect(context.Check("* 2 sum 1 2 end"), Equals, nil)
+    res, err := context.EvalNode(n)
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 6)
+    complete, err := context.IsComplete("* 2 sum 1 2 end")
+    c.Expect(complete, Equals, true)
+  })
+  c.Specify("Type checking calls the token function.", func() {
+    typ, err := context.TypeCheck("sum 1 end")
+    c.Assume(err, Equals, nil)
+    c.Expect(typ, Equals, reflect.TypeOf(0))
+  })
+  c.Specify("Compiled expressions leave the terms alone.", func() {
+    context.SetConstantFolding(true)
+    defer context.SetConstantFolding(false)
+    e, err := context.Compile("+ x sum 1 2 end", "x")
+    c.Assume(err, Equals, nil)
+    res, err := e.EvalWith(map[string]reflect.Value{"x": reflect.ValueOf(4)})
+    c.Assume(err, Equals, nil)
+    c.Expect(int(res[0].Int()), Equals, 7)
+  })
+  c.Specify("Panics become errors.", func() {
+    context.AddTokenFunc("boom", func(polish.TokenCursor) ([]reflect.Value, int, error) { panic("boom") })
+    _, err := context.Eval("boom")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Parse("boom")
+    c.Expect(err, Not(Equals), nil)
+  })
+  c.Specify("Token functions need a function and a free name.", func() {
+    c.Expect(context.AddTokenFunc("other", nil), Not(Equals), nil)
+    c.Expect(context.AddTokenFunc("sum", sumTokens), Not(Equals), nil)
+    c.Expect(context.AddTokenFunc("map", sumTokens), Not(Equals), nil)
+  })
+}
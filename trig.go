@@ -0,0 +1,70 @@
+package polish
+
+import (
+  "fmt"
+  "math"
+)
+
+// Adds trigonometric functions over float64s to the Context, all of which
+// work in radians.
+//   Functions: sin cos tan asin acos atan atan2 deg rad
+//              sinh cosh tanh asinh acosh atanh
+//   Constants: tau
+// atan2 y x is the angle of the point (x, y), in the range [-pi, pi].  deg
+// converts radians to degrees and rad converts degrees to radians, so
+// sin rad 90.0 is 1.  tau is 2 * pi.  asin and acos of values outside [-1, 1],
+// acosh of values below 1, and atanh of values outside [-1, 1] are NaN, or
+// an Error if SetDomainErrors is on.
+func AddTrigContext(c *Context) {
+  c.addBuiltin("sin", math.Sin, "Sine of an angle in radians.")
+  c.addBuiltin("cos", math.Cos, "Cosine of an angle in radians.")
+  c.addBuiltin("tan", math.Tan, "Tangent of an angle in radians.")
+  c.addBuiltin("asin", c.domainChecked("asin", math.Asin), "Inverse sine, in radians.")
+  c.addBuiltin("acos", c.domainChecked("acos", math.Acos), "Inverse cosine, in radians.")
+  c.addBuiltin("atan", math.Atan, "Inverse tangent, in radians.")
+  c.addBuiltin("atan2", math.Atan2, "Angle of the point (x, y) in radians, atan2 y x.")
+  c.addBuiltin("deg", func(r float64) float64 { return r * 180 / math.Pi }, "Radians converted to degrees.")
+  c.addBuiltin("rad", func(d float64) float64 { return d * math.Pi / 180 }, "Degrees converted to radians.")
+  c.addBuiltin("sinh", math.Sinh, "Hyperbolic sine.")
+  c.addBuiltin("cosh", math.Cosh, "Hyperbolic cosine.")
+  c.addBuiltin("tanh", math.Tanh, "Hyperbolic tangent.")
+  c.addBuiltin("asinh", math.Asinh, "Inverse hyperbolic sine.")
+  c.addBuiltin("acosh", c.domainChecked("acosh", math.Acosh), "Inverse hyperbolic cosine, x must be at least 1.")
+  c.addBuiltin("atanh", c.domainChecked("atanh", math.Atanh), "Inverse hyperbolic tangent, x must be in [-1, 1].")
+  c.SetValue("tau", 2*math.Pi)
+}
+
+// When set, functions from AddTrigContext that are given a value outside of
+// their domain, such as acosh 0.5, are an Error instead of producing NaN.  A
+// NaN argument still produces NaN, since it is not outside of the domain so
+// much as already undefined.  As with SetNaNMode, the setting is read when a
+// function is called.
+func (c *Context) SetDomainErrors(domain_errors bool) {
+  c.domain_errors = domain_errors
+}
+
+// Returns f, except that if domain errors are on it panics rather than turning
+// an argument that is not NaN into NaN.
+func (c *Context) domainChecked(name string, f func(float64) float64) func(float64) float64 {
+  return func(x float64) float64 {
+    y := f(x)
+    if c.domain_errors && math.IsNaN(y) && !math.IsNaN(x) {
+      panic(fmt.Sprintf("Cannot take %s %v, which is outside of its domain.", name, x))
+    }
+    return y
+  }
+}
+
+// Returns a new Context with everything that most uses of float64 math need,
+// which is AddFloat64MathContext, AddTrigContext and AddBooleanContext, with
+// the default numeric type set to Float so that integer-looking literals such
+// as 2 are float64s.  More functions and contexts can be added to it as
+// usual, but contexts that take ints will not accept literal arguments, see
+// SetDefaultNumeric.
+func MakeMathContext() *Context {
+  c := MakeContext()
+  AddFloat64AndBooleanContext(c)
+  AddTrigContext(c)
+  c.SetDefaultNumeric(Float)
+  return c
+}
@@ -0,0 +1,103 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "math"
+)
+
+func TrigContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddTrigContext(context)
+  expectFloat := func(expression string, expected float64) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(math.Abs(res[0].Float()-expected) < 1e-12, Equals, true)
+  }
+  c.Specify("Trig functions work in radians.", func() {
+    expectFloat("sin / pi 2.0", 1)
+    expectFloat("cos pi", -1)
+    expectFloat("tan / pi 4.0", 1)
+    expectFloat("asin 1.0", math.Pi/2)
+    expectFloat("acos 1.0", 0)
+    expectFloat("atan 1.0", math.Pi/4)
+    expectFloat("atan2 1.0 -1.0", 3*math.Pi/4)
+  })
+  c.Specify("Angles convert between degrees and radians.", func() {
+    expectFloat("sin rad 90.0", 1)
+    expectFloat("deg pi", 180)
+    expectFloat("deg rad 37.5", 37.5)
+    expectFloat("/ tau pi", 2)
+  })
+}
+
+func HyperbolicSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddTrigContext(context)
+  expectFloat := func(expression string, expected float64) {
+    res, err := context.Eval(expression)
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(math.Abs(res[0].Float()-expected) < 1e-12, Equals, true)
+  }
+  c.Specify("Hyperbolic functions match their known values.", func() {
+    expectFloat("tanh 0.0", 0)
+    expectFloat("sinh 0.0", 0)
+    expectFloat("cosh 0.0", 1)
+    expectFloat("sinh 1.0", (math.E-1/math.E)/2)
+    expectFloat("cosh 1.0", (math.E+1/math.E)/2)
+    expectFloat("acosh 1.0", 0)
+    expectFloat("asinh sinh 0.75", 0.75)
+    expectFloat("atanh tanh -0.5", -0.5)
+    expectFloat("- * cosh 2.0 cosh 2.0 * sinh 2.0 sinh 2.0", 1)
+  })
+  c.Specify("Values outside of a domain are NaN by default.", func() {
+    res, err := context.Eval("acosh 0.5")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.IsNaN(res[0].Float()), Equals, true)
+    res, err = context.Eval("atanh 2.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.IsNaN(res[0].Float()), Equals, true)
+  })
+  c.Specify("Domain errors can be turned into Errors.", func() {
+    context := polish.MakeContext()
+    polish.AddFloat64MathContext(context)
+    polish.AddTrigContext(context)
+    context.SetDomainErrors(true)
+    for _, expression := range []string{"acosh 0.5", "atanh 2.0", "asin 1.5", "acos -2.0"} {
+      _, err := context.Eval(expression)
+      c.Expect(err, Not(Equals), nil)
+    }
+    res, err := context.Eval("acosh / 0.0 0.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.IsNaN(res[0].Float()), Equals, true)
+    res, err = context.Eval("atanh 1.0")
+    c.Assume(err, Equals, nil)
+    c.Expect(math.IsInf(res[0].Float(), 1), Equals, true)
+    context.SetDomainErrors(false)
+    _, err = context.Eval("acosh 0.5")
+    c.Expect(err, Equals, nil)
+  })
+}
+
+func MathContextSpec(c gospec.Context) {
+  context := polish.MakeMathContext()
+  c.Specify("The math context has float, trig and boolean functions.", func() {
+    res, err := context.Eval("&& < sin 0 0.5 > * 2 pi 6")
+    c.Assume(err, Equals, nil)
+    c.Assume(len(res), Equals, 1)
+    c.Expect(res[0].Bool(), Equals, true)
+    res, err = context.Eval("+ 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 3.0)
+  })
+  c.Specify("Each call makes a separate Context.", func() {
+    c.Assume(context.SetValue("x", 1.0), Equals, nil)
+    _, err := polish.MakeMathContext().Eval("+ x 1")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
@@ -0,0 +1,63 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+  "strconv"
+)
+
+// A Tuple groups several values, possibly of different types, into a single
+// value.  The tuple special form makes one and untuple spreads one back out
+// into separate values:
+//   tuple n e1 e2 ...   takes exactly n values from the subexpressions that
+//                       follow, as a function with n parameters would, and
+//                       produces a single Tuple holding them.
+//   untuple n e         takes one subexpression, which must produce a single
+//                       Tuple of length n, and produces its n values.
+// n must be written as a literal non-negative integer, so that the number of
+// values is known without evaluating anything.  A Tuple is otherwise an
+// ordinary value: it is passed whole to a parameter of type Tuple, so
+// functions can accept and return them, and it is never unpacked implicitly.
+// This gives a name to what multi-value functions do implicitly, so that
+// f (a, b) can be written as f tuple 2 a b and keep its values together
+// however many there are.  Format renders a Tuple as its values in
+// parentheses.
+type Tuple []reflect.Value
+
+// Returns the number of values that follow tuple or untuple, which is read
+// from the first of terms.
+func tupleCount(form string, terms []string) (int, error) {
+  if len(terms) == 0 {
+    return 0, &Error{fmt.Sprintf("Unexpected end of expression: '%s' needs a number of values.", form), nil, nil}
+  }
+  n, err := strconv.Atoi(terms[0])
+  if err != nil || n < 0 {
+    return 0, &Error{fmt.Sprintf("'%s' must be followed by a literal number of values, not '%s'.", form, terms[0]), nil, nil}
+  }
+  return n, nil
+}
+
+// Produces the values of a complete tuple or untuple frame.
+func finishTuple(fr *frame) ([]reflect.Value, error) {
+  if fr.term == "tuple" {
+    if len(fr.args) != fr.count {
+      return nil, &Error{fmt.Sprintf("'tuple %d' was given %d values.", fr.count, len(fr.args)), nil, nil}
+    }
+    return []reflect.Value{reflect.ValueOf(Tuple(append([]reflect.Value(nil), fr.args...)))}, nil
+  }
+  if len(fr.args) != 1 {
+    return nil, &Error{fmt.Sprintf("'untuple %d' needs a single Tuple, but its expression produced %d values.", fr.count, len(fr.args)), nil, nil}
+  }
+  var t Tuple
+  ok := false
+  if fr.args[0].IsValid() {
+    t, ok = fr.args[0].Interface().(Tuple)
+  }
+  if !ok {
+    return nil, &Error{fmt.Sprintf("'untuple %d' needs a Tuple, not a %v.", fr.count, typeOf(fr.args[0])), nil, nil}
+  }
+  if len(t) != fr.count {
+    return nil, &Error{fmt.Sprintf("'untuple %d' was given a Tuple of %d values.", fr.count, len(t)), nil, nil}
+  }
+  return t, nil
+}
@@ -0,0 +1,77 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func TupleSpec(c gospec.Context) {
+  for _, engine := range []polish.Engine{polish.Recursive, polish.Iterative} {
+    context := polish.MakeContext()
+    polish.AddIntMathContext(context)
+    context.AddFunc("makeTwo", func() (int, int) { return 1, 2 })
+    context.AddFunc("swap", func(t polish.Tuple) polish.Tuple { return polish.Tuple{t[1], t[0]} })
+    context.AddFunc("size", func(t polish.Tuple) int { return len(t) })
+    context.SetEngine(engine)
+    c.Specify("tuple groups values into a single Tuple.", func() {
+      res, err := context.Eval("tuple 4 1 + 2 3 makeTwo")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      t := res[0].Interface().(polish.Tuple)
+      c.Assume(len(t), Equals, 4)
+      c.Expect(int(t[0].Int()), Equals, 1)
+      c.Expect(int(t[1].Int()), Equals, 5)
+      c.Expect(int(t[2].Int()), Equals, 1)
+      c.Expect(int(t[3].Int()), Equals, 2)
+      res, err = context.Eval("size tuple 0")
+      c.Assume(err, Equals, nil)
+      c.Expect(int(res[0].Int()), Equals, 0)
+      _, err = context.Eval("tuple 2 7 makeTwo")
+      c.Expect(err, Not(Equals), nil)
+    })
+    c.Specify("Tuples are passed whole and unpacked by untuple.", func() {
+      res, err := context.Eval("size tuple 2 makeTwo")
+      c.Assume(err, Equals, nil)
+      c.Expect(int(res[0].Int()), Equals, 2)
+      res, err = context.Eval("- untuple 2 swap tuple 2 10 3")
+      c.Assume(err, Equals, nil)
+      c.Assume(len(res), Equals, 1)
+      c.Expect(int(res[0].Int()), Equals, -7)
+      _, err = context.Eval("untuple 3 tuple 2 1 2")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("untuple 1 5")
+      c.Expect(err, Not(Equals), nil)
+    })
+    c.Specify("The number of values must be a literal.", func() {
+      _, err := context.Eval("tuple two 1 2")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("tuple")
+      c.Expect(err, Not(Equals), nil)
+      _, err = context.Eval("tuple 2 1")
+      c.Expect(err, Not(Equals), nil)
+    })
+    c.Specify("Tuples are formatted as their values in parentheses.", func() {
+      s, err := context.EvalToString("tuple 3 1 tuple 1 2 3")
+      c.Assume(err, Equals, nil)
+      c.Expect(s, Equals, "(1 (2) 3)")
+    })
+  }
+  context := polish.MakeContext()
+  polish.AddIntMathContext(context)
+  c.Specify("Parse and TypeCheck understand tuple and untuple.", func() {
+    root, err := context.Parse("+ untuple 2 tuple 2 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(root.Children[0].Term, Equals, "untuple")
+    c.Expect(len(root.Children[0].Children), Equals, 2)
+    c.Expect(len(root.Children[0].Children[1].Children), Equals, 3)
+    t, err := context.TypeCheck("tuple 2 1 2")
+    c.Assume(err, Equals, nil)
+    c.Expect(t, Equals, reflect.TypeOf(polish.Tuple{}))
+    _, err = context.TypeCheck("untuple 2 + 1 2")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Parse("untuple 2")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
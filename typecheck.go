@@ -0,0 +1,264 @@
+package polish
+
+import (
+  "fmt"
+  "reflect"
+)
+
+// Works out the type of an expression's result without calling any functions,
+// by propagating types from literals, values, and the signatures of functions,
+// and checking that every argument can be passed to its parameter.  Literals
+// are typed by trying the parse order, exactly as Eval would parse them.  The
+// expression must produce exactly one value.  Some types cannot be known
+// without evaluating something, such as lazy values and terms that would be
+// passed to the resolver; those are not checked, and if the result depends on
+// one then the returned type is nil.
+func (c *Context) TypeCheck(expression string) (reflect.Type, error) {
+  n, err := c.Parse(expression)
+  if err != nil {
+    return nil, err
+  }
+  tc := typeChecker{c: c, bound: make(map[string]reflect.Type)}
+  types, err := tc.check(n)
+  if err != nil {
+    return nil, err
+  }
+  if len(types) != 1 {
+    return nil, &Error{fmt.Sprintf("Expected (%s) to produce 1 value, but it produces %d.", expression, len(types)), nil, nil}
+  }
+  return types[0], nil
+}
+
+type typeChecker struct {
+  c *Context
+
+  // Types of the names given by bind, which last for the rest of the
+  // expression.  A nil type is unknown.
+  bound map[string]reflect.Type
+}
+
+// Returns the types of the values that n produces, a nil type is unknown.
+func (tc *typeChecker) check(n *Node) ([]reflect.Type, error) {
+  c := tc.c
+  switch n.Term {
+  case "[":
+    elems, err := tc.checkChildren(n.Children)
+    if err != nil {
+      return nil, err
+    }
+    if len(elems) == 0 {
+      return nil, &Error{"Cannot determine the type of an empty list.", nil, nil}
+    }
+    for _, elem := range elems {
+      if elem == nil {
+        return []reflect.Type{nil}, nil
+      }
+      if elem != elems[0] {
+        return nil, &Error{fmt.Sprintf("List elements must all have the same type, found %v and %v.", elems[0], elem), nil, nil}
+      }
+    }
+    return []reflect.Type{reflect.SliceOf(elems[0])}, nil
+
+  case "bind":
+    types, err := tc.check(n.Children[1])
+    if err != nil {
+      return nil, err
+    }
+    for i, name := range n.Children[0].Children {
+      tc.bound[name.Term] = types[i]
+    }
+    return tc.check(n.Children[2])
+
+  case "nth":
+    index, err := tc.check(n.Children[0])
+    if err != nil {
+      return nil, err
+    }
+    if index[0] != nil {
+      switch index[0].Kind() {
+      case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+      default:
+        return nil, &Error{fmt.Sprintf("The index given to 'nth' must be an integer, not a %v.", index[0]), nil, nil}
+      }
+    }
+    types, err := tc.check(n.Children[1])
+    if err != nil {
+      return nil, err
+    }
+    // The index is only known here if it is a literal.
+    if len(n.Children[0].Children) == 0 {
+      if val, _, err := c.parseLiteral(n.Children[0].Term); err == nil && val.IsValid() && val.Kind() == reflect.Int {
+        if _, err := nth(val, make([]reflect.Value, len(types))); err != nil {
+          return nil, err
+        }
+        return []reflect.Type{types[val.Int()]}, nil
+      }
+    }
+    return []reflect.Type{nil}, nil
+
+  case "tuple":
+    if _, err := tc.checkChildren(n.Children[1:]); err != nil {
+      return nil, err
+    }
+    return []reflect.Type{reflect.TypeOf(Tuple(nil))}, nil
+
+  case "untuple":
+    types, err := tc.check(n.Children[1])
+    if err != nil {
+      return nil, err
+    }
+    if types[0] != nil && types[0] != reflect.TypeOf(Tuple(nil)) {
+      return nil, &Error{fmt.Sprintf("'untuple' needs a Tuple, not a %v.", types[0]), nil, nil}
+    }
+    // The types of the values in a Tuple are not known.
+    count, _ := tupleCount(n.Term, []string{n.Children[0].Term})
+    return make([]reflect.Type, count), nil
+
+  case "apply":
+    types, err := tc.check(n.Children[0])
+    if err != nil {
+      return nil, err
+    }
+    if types[0] == nil {
+      return nil, &Error{"The type of the func given to 'apply' cannot be known without evaluating it.", nil, nil}
+    }
+    if err := checkApplied(types[0]); err != nil {
+      return nil, err
+    }
+    typ := types[0]
+    args, err := tc.checkChildren(n.Children[1:])
+    if err != nil {
+      return nil, err
+    }
+    for i := 0; i < typ.NumIn(); i++ {
+      if args[i] != nil && !args[i].AssignableTo(typ.In(i)) {
+        return nil, &Error{fmt.Sprintf("Argument %d of 'apply' is %s, which cannot be used as %s.", i+1, withArticle(args[i]), withArticle(typ.In(i))), nil, nil}
+      }
+    }
+    var outs []reflect.Type
+    for i := 0; i < typ.NumOut(); i++ {
+      outs = append(outs, typ.Out(i))
+    }
+    return append(outs, args[typ.NumIn():]...), nil
+
+  case "fold", "map":
+    f, _ := c.lookupFunc(n.Children[0].Term)
+    typ := f.f.Type()
+    args, err := tc.checkChildren(n.Children[1:])
+    if err != nil {
+      return nil, err
+    }
+    list := args[len(args)-1]
+    if list != nil && list.Kind() != reflect.Slice && list.Kind() != reflect.Array {
+      return nil, &Error{fmt.Sprintf("'%s' needs a list, but was given a %v.", n.Term, list), nil, nil}
+    }
+    params := f.params()
+    if list != nil && !list.Elem().AssignableTo(params[len(params)-1]) {
+      return nil, &Error{fmt.Sprintf("'%s' cannot pass elements of a %v to '%s', which takes a %v.", n.Term, list, n.Children[0].Term, params[len(params)-1]), nil, nil}
+    }
+    if n.Term == "map" {
+      return []reflect.Type{reflect.SliceOf(typ.Out(0))}, nil
+    }
+    if args[0] != nil && !args[0].AssignableTo(params[0]) {
+      return nil, &Error{fmt.Sprintf("'fold' cannot pass a %v to '%s', which takes a %v.", args[0], n.Children[0].Term, params[0]), nil, nil}
+    }
+    if !typ.Out(0).AssignableTo(params[0]) {
+      return nil, &Error{fmt.Sprintf("'fold' cannot pass the %v results of '%s' back to it, it takes a %v.", typ.Out(0), n.Children[0].Term, params[0]), nil, nil}
+    }
+    return []reflect.Type{typ.Out(0)}, nil
+  }
+  if t, ok := tc.bound[n.Term]; ok {
+    return []reflect.Type{t}, nil
+  }
+  if f, ok := c.lookupFunc(n.Term); ok && f.token {
+    var terms []string
+    for _, child := range n.Children {
+      terms = append(terms, child.Term)
+    }
+    vs, _, err := callTokenFunc(n.Term, f, terms)
+    if err != nil {
+      return nil, err
+    }
+    var types []reflect.Type
+    for _, v := range vs {
+      types = append(types, typeOf(v))
+    }
+    return types, nil
+  }
+  if f, ok := c.lookupFunc(n.Term); ok {
+    args, err := tc.checkChildren(n.Children)
+    if err != nil {
+      return nil, err
+    }
+    typ := f.f.Type()
+    if !typ.IsVariadic() {
+      for i, param := range f.params() {
+        if args[i] != nil && !args[i].AssignableTo(param) {
+          return nil, &Error{fmt.Sprintf("Argument %d of '%s' is %s, which cannot be used as %s.%s", i+1, n.Term, withArticle(args[i]), withArticle(param), numericHint(args[i], param)), nil, nil}
+        }
+      }
+    }
+    var types []reflect.Type
+    for i := 0; i < typ.NumOut(); i++ {
+      types = append(types, resultType(typ.Out(i)))
+    }
+    return append(types, args[f.num:]...), nil
+  }
+  if val, ok := c.overrides[n.Term]; ok {
+    return []reflect.Type{typeOf(val)}, nil
+  }
+  if val, ok := c.vals[n.Term]; ok {
+    return []reflect.Type{typeOf(val)}, nil
+  }
+  if _, ok := c.lazy[n.Term]; ok {
+    return []reflect.Type{nil}, nil
+  }
+  if val, ok := c.historyValue(n.Term); ok {
+    return []reflect.Type{typeOf(val)}, nil
+  }
+  val, _, err := c.parseLiteral(n.Term)
+  if err != nil {
+    return nil, err
+  }
+  if val != (reflect.Value{}) {
+    return []reflect.Type{val.Type()}, nil
+  }
+  if c.resolver != nil {
+    return []reflect.Type{nil}, nil
+  }
+  if c.default_value.IsValid() {
+    return []reflect.Type{c.default_value.Type()}, nil
+  }
+  return nil, &Error{fmt.Sprintf("Unable to parse term: unknown value '%s'", n.Term), nil, nil}
+}
+
+// Returns the types of the values produced by each of nodes, in order.
+func (tc *typeChecker) checkChildren(nodes []*Node) ([]reflect.Type, error) {
+  var types []reflect.Type
+  for _, child := range nodes {
+    child_types, err := tc.check(child)
+    if err != nil {
+      return nil, err
+    }
+    types = append(types, child_types...)
+  }
+  return types, nil
+}
+
+// Returns the type of a value returned as out, which is nil for interface{}
+// since a function's interface{} results are replaced by what they hold.
+func resultType(out reflect.Type) reflect.Type {
+  if out.Kind() == reflect.Interface && out.NumMethod() == 0 {
+    return nil
+  }
+  return out
+}
+
+// Returns the type of v, or nil if v is invalid.
+func typeOf(v reflect.Value) reflect.Type {
+  if !v.IsValid() {
+    return nil
+  }
+  return v.Type()
+}
@@ -0,0 +1,67 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func TypeCheckSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddBooleanContext(context)
+  calls := 0
+  context.AddFunc("round", func(x float64) int { calls++; return int(x + 0.5) })
+  context.AddFunc("makeTwo", func() (float64, float64) { calls++; return 1, 2 })
+  context.AddFunc("sum", func(v []float64) float64 { calls++; return 0 })
+  context.SetLazyValue("later", func() interface{} { calls++; return 1.0 })
+  expectType := func(expression string, expected reflect.Type) {
+    typ, err := context.TypeCheck(expression)
+    c.Expect(err, Equals, nil)
+    c.Expect(typ, Equals, expected)
+  }
+  expectError := func(expression string) {
+    _, err := context.TypeCheck(expression)
+    c.Expect(err, Not(Equals), nil)
+  }
+  c.Specify("Result types are propagated from function signatures.", func() {
+    expectType("+ 1.0 2.0", reflect.TypeOf(0.0))
+    expectType("round * pi 2.0", reflect.TypeOf(0))
+    expectType("< 1.0 pi", reflect.TypeOf(true))
+    expectType("&& true < 1.0 2.0", reflect.TypeOf(true))
+    expectType("- makeTwo", reflect.TypeOf(0.0))
+    expectType("sum [1.0 2.0 makeTwo]", reflect.TypeOf(0.0))
+    expectType("[1 2]", reflect.TypeOf([]int{}))
+    expectType("map round [1.0 2.0]", reflect.TypeOf([]int{}))
+    expectType("fold + 0.0 [1.0 2.0]", reflect.TypeOf(0.0))
+    expectType("bind (a b) makeTwo round + a b", reflect.TypeOf(0))
+  })
+  c.Specify("Literals are typed by the parse order.", func() {
+    expectType("1", reflect.TypeOf(0))
+    expectType("1.5", reflect.TypeOf(0.0))
+    expectType("'x'", reflect.TypeOf('x'))
+  })
+  c.Specify("Type errors are reported.", func() {
+    expectError("+ 1 2.0")
+    expectError("round true")
+    expectError("&& 1.0 true")
+    expectError("sum [1 2]")
+    expectError("[1 2.0]")
+    expectError("map round [1 2]")
+    expectError("bind (a) 1 round a")
+  })
+  c.Specify("Only expressions producing one value are accepted.", func() {
+    expectError("makeTwo")
+    expectError("+ 1.0")
+  })
+  c.Specify("No functions or lazy values are evaluated.", func() {
+    calls = 0
+    context.TypeCheck("+ later round sum [1.0 makeTwo]")
+    c.Expect(calls, Equals, 0)
+  })
+  c.Specify("Types that need evaluation are unknown.", func() {
+    expectType("later", nil)
+    expectType("+ later 1.0", reflect.TypeOf(0.0))
+  })
+}
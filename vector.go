@@ -0,0 +1,91 @@
+package polish
+
+import (
+  "fmt"
+  "math"
+)
+
+func checkLengths(name string, a, b []float64) {
+  if len(a) != len(b) {
+    panic(fmt.Sprintf("Cannot apply %s to vectors of length %d and %d.", name, len(a), len(b)))
+  }
+}
+
+func vAdd(a, b []float64) []float64 {
+  checkLengths("v+", a, b)
+  r := make([]float64, len(a))
+  for i := range a {
+    r[i] = a[i] + b[i]
+  }
+  return r
+}
+
+func vSub(a, b []float64) []float64 {
+  checkLengths("v-", a, b)
+  r := make([]float64, len(a))
+  for i := range a {
+    r[i] = a[i] - b[i]
+  }
+  return r
+}
+
+func vMul(a, b []float64) []float64 {
+  checkLengths("v*", a, b)
+  r := make([]float64, len(a))
+  for i := range a {
+    r[i] = a[i] * b[i]
+  }
+  return r
+}
+
+func vDot(a, b []float64) float64 {
+  checkLengths("dot", a, b)
+  var r float64
+  for i := range a {
+    r += a[i] * b[i]
+  }
+  return r
+}
+
+func vCross(a, b []float64) []float64 {
+  if len(a) != 3 || len(b) != 3 {
+    panic(fmt.Sprintf("Cannot apply cross to vectors of length %d and %d, both must have length 3.", len(a), len(b)))
+  }
+  return []float64{
+    a[1]*b[2] - a[2]*b[1],
+    a[2]*b[0] - a[0]*b[2],
+    a[0]*b[1] - a[1]*b[0],
+  }
+}
+
+func vMag(a []float64) float64 {
+  return math.Sqrt(vDot(a, a))
+}
+
+func vScale(s float64, a []float64) []float64 {
+  r := make([]float64, len(a))
+  for i := range a {
+    r[i] = s * a[i]
+  }
+  return r
+}
+
+// Adds several operators on []float64 vectors to the Context.  Vectors can be
+// written as lists, like [1.0 2.0 3.0].  The elementwise operators are named
+// differently from the scalar ones so that this context can be used alongside
+// AddFloat64MathContext.  Applying an operator to vectors of different lengths
+// is an error.
+//   Functions: v+ v- v* (elementwise)
+//              dot   (dot product)
+//              cross (cross product, 3D only)
+//              mag   (magnitude)
+//              scale (multiplies a vector by a float64, as in scale 2.0 v)
+func AddVectorContext(c *Context) {
+  c.addBuiltin("v+", vAdd, "Elementwise sum of two vectors.")
+  c.addBuiltin("v-", vSub, "Elementwise difference of two vectors.")
+  c.addBuiltin("v*", vMul, "Elementwise product of two vectors.")
+  c.addBuiltin("dot", vDot, "Dot product of two vectors.")
+  c.addBuiltin("cross", vCross, "Cross product of two 3D vectors.")
+  c.addBuiltin("mag", vMag, "Magnitude of a vector.")
+  c.addBuiltin("scale", vScale, "Multiplies a vector by a float64.")
+}
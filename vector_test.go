@@ -0,0 +1,68 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "reflect"
+)
+
+func ListLiteralSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  c.Specify("Lists of values become slices.", func() {
+    res, err := context.Eval("[1.0 + 1.0 1.0 pi]")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(reflect.DeepEqual(res[0].Interface(), []float64{1, 2, 3.141592653589793}), Equals, true)
+    res, err = context.Eval("[ [ 1 2 ] [ 3 ] ]")
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(reflect.DeepEqual(res[0].Interface(), [][]int{{1, 2}, {3}}), Equals, true)
+  })
+  c.Specify("Malformed lists are errors.", func() {
+    _, err := context.Eval("[1.0 2.0")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("+ 1.0 ]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("[]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("[1.0 2]")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
+
+func VectorContextSpec(c gospec.Context) {
+  context := polish.MakeContext()
+  polish.AddFloat64MathContext(context)
+  polish.AddVectorContext(context)
+  expectVector := func(expression string, expected []float64) {
+    res, err := context.Eval(expression)
+    c.Assume(len(res), Equals, 1)
+    c.Assume(err, Equals, nil)
+    c.Expect(reflect.DeepEqual(res[0].Interface(), expected), Equals, true)
+  }
+  c.Specify("Elementwise operators work.", func() {
+    expectVector("v+ [1.0 2.0 3.0] [4.0 5.0 6.0]", []float64{5, 7, 9})
+    expectVector("v- [1.0 2.0 3.0] [4.0 5.0 6.0]", []float64{-3, -3, -3})
+    expectVector("v* [1.0 2.0 3.0] [4.0 5.0 6.0]", []float64{4, 10, 18})
+    expectVector("scale 2.0 [1.0 -2.0]", []float64{2, -4})
+  })
+  c.Specify("Products and magnitude work.", func() {
+    expectVector("cross [1.0 0.0 0.0] [0.0 1.0 0.0]", []float64{0, 0, 1})
+    res, err := context.Eval("dot [1.0 2.0 3.0] [4.0 5.0 6.0]")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 32.0)
+    res, err = context.Eval("mag [3.0 4.0]")
+    c.Assume(err, Equals, nil)
+    c.Expect(res[0].Float(), Equals, 5.0)
+  })
+  c.Specify("Mismatched lengths are errors.", func() {
+    _, err := context.Eval("v+ [1.0 2.0] [1.0]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("dot [1.0 2.0] [1.0]")
+    c.Expect(err, Not(Equals), nil)
+    _, err = context.Eval("cross [1.0 2.0] [1.0 2.0]")
+    c.Expect(err, Not(Equals), nil)
+  })
+}
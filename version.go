@@ -0,0 +1,53 @@
+package polish
+
+// The version of this package, following semantic versioning.
+const Version = "0.1.0"
+
+// Names of the optional contexts and evaluation features that are available,
+// see Capabilities.
+var capabilities = []string{
+  "apply",
+  "bigfloat",
+  "bind",
+  "boolean",
+  "bytes",
+  "comments",
+  "compile",
+  "concurrent",
+  "decimal",
+  "float64",
+  "fold",
+  "grouping",
+  "history",
+  "int",
+  "int32",
+  "int64",
+  "iterative",
+  "list",
+  "map",
+  "matrix",
+  "nth",
+  "numeric",
+  "parse",
+  "script",
+  "stats",
+  "string",
+  "timeout",
+  "tokenfunc",
+  "trig",
+  "tuple",
+  "vector",
+}
+
+// Returns the names of the contexts and evaluation features that this version
+// of the package supports, in sorted order, so that tools can check for a
+// feature rather than for a Version.  Contexts are named after the functions
+// that add them, such as "vector" for AddVectorContext, and features are named
+// after the special forms or methods that provide them, such as "fold",
+// "compile", "history" for SetHistorySize, or "bytes" for EvalBytes.  Every
+// special form, with untuple listed as "tuple", and every optional way of
+// writing or evaluating expressions has a name.  Names are never removed once
+// they have been added.
+func Capabilities() []string {
+  return append([]string(nil), capabilities...)
+}
@@ -0,0 +1,28 @@
+package polish_test
+
+import (
+  . "github.com/orfjackal/gospec/src/gospec"
+  "github.com/orfjackal/gospec/src/gospec"
+  "github.com/runningwild/polish"
+  "sort"
+)
+
+func CapabilitiesSpec(c gospec.Context) {
+  c.Specify("Version is set.", func() {
+    c.Expect(polish.Version, Not(Equals), "")
+  })
+  c.Specify("Capabilities are sorted and include the built-in contexts.", func() {
+    caps := polish.Capabilities()
+    c.Expect(sort.StringsAreSorted(caps), Equals, true)
+    c.Expect(caps, Contains, "vector")
+    c.Expect(caps, Contains, "float64")
+    c.Expect(caps, Contains, "fold")
+    c.Expect(caps, Contains, "history")
+    c.Expect(caps, Contains, "tokenfunc")
+  })
+  c.Specify("Changing the result does not change later results.", func() {
+    caps := polish.Capabilities()
+    caps[0] = "changed"
+    c.Expect(polish.Capabilities(), Not(Contains), "changed")
+  })
+}